@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+// asyncAPIDocument describes the item event stream of the configured broker;
+// main regenerates it from the loaded configuration
+var asyncAPIDocument = newAsyncAPIDocument(defaultConfig().Broker)
+
+// jsonSchemas generates JSON Schemas from Go types, collecting named structs
+// as reusable component schemas
+type jsonSchemas map[string]any
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	eventTypeType = reflect.TypeOf(events.EventType(""))
+)
+
+// schemaFor returns the schema of t, adding named structs to s and
+// referencing them
+func (s jsonSchemas) schemaFor(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == eventTypeType:
+		return map[string]any{"type": "string", "enum": events.EventTypes()}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.schemaFor(t.Elem())
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": s.schemaFor(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Struct:
+		if _, ok := s[t.Name()]; !ok {
+			s[t.Name()] = nil // guards against recursive types
+			s[t.Name()] = s.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+// structSchema describes the JSON encoding of struct type t. Fields without
+// omitempty are required.
+func (s jsonSchemas) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// newAsyncAPIDocument builds the AsyncAPI 3.0 document for the item events
+// published with cfg, generating payload schemas from ItemEvent
+func newAsyncAPIDocument(cfg BrokerConfig) map[string]any {
+	schemas := jsonSchemas{}
+	schemas.schemaFor(reflect.TypeOf(events.ItemEvent{}))
+
+	protocol := "amqp"
+	if u, err := url.Parse(cfg.URL); err == nil && u.Scheme == "amqps" {
+		protocol = "amqps"
+	}
+
+	messages := map[string]any{}
+	messageRefs := []any{}
+	for _, eventType := range events.EventTypes() {
+		name := messageName(eventType)
+		messages[name] = map[string]any{
+			"name":        string(eventType),
+			"title":       string(eventType),
+			"contentType": "application/json",
+			"headers":     map[string]any{"$ref": "#/components/schemas/MessageHeaders"},
+			"payload":     map[string]any{"$ref": "#/components/schemas/ItemEvent"},
+			"correlationId": map[string]any{
+				"description": "ID of the HTTP request that caused the event",
+				"location":    "$message.header#/" + events.RequestIDHeader,
+			},
+			"bindings": map[string]any{"amqp": map[string]any{"messageType": string(eventType), "bindingVersion": "0.3.0"}},
+		}
+		messageRefs = append(messageRefs, map[string]any{"$ref": "#/channels/itemEvents/messages/" + name})
+	}
+	schemas["MessageHeaders"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			events.RequestIDHeader:   map[string]any{"type": "string", "description": "ID of the originating HTTP request"},
+			events.TraceparentHeader: map[string]any{"type": "string", "description": "W3C trace context of the publish span"},
+		},
+		"required": []string{events.TraceparentHeader},
+	}
+
+	queue := map[string]any{"name": cfg.Queue, "durable": true, "exclusive": false, "autoDelete": false, "vhost": "/"}
+	if cfg.DeadLetterExchange != "" {
+		queue["x-dead-letter-exchange"] = cfg.DeadLetterExchange
+	}
+	channel := map[string]any{
+		"description": "Item change events",
+		"messages":    messages,
+	}
+	if cfg.Exchange == "" {
+		channel["address"] = cfg.Queue
+		channel["bindings"] = map[string]any{"amqp": map[string]any{"is": "queue", "queue": queue, "bindingVersion": "0.3.0"}}
+	} else {
+		channel["address"] = "{tenant}.{eventType}"
+		channel["parameters"] = map[string]any{
+			"tenant":    map[string]any{"description": "Tenant the item belongs to"},
+			"eventType": map[string]any{"description": "Event type", "enum": events.EventTypes()},
+		}
+		channel["bindings"] = map[string]any{"amqp": map[string]any{
+			"is":             "routingKey",
+			"exchange":       map[string]any{"name": cfg.Exchange, "type": "topic", "durable": true, "autoDelete": false, "vhost": "/"},
+			"bindingVersion": "0.3.0",
+		}}
+		channel["x-queue-bindings"] = []any{map[string]any{"queue": queue, "routingKey": "*.item.*"}}
+	}
+
+	return map[string]any{
+		"asyncapi": "3.0.0",
+		"info": map[string]any{
+			"title":       "Go-server-crud item events",
+			"version":     "1.0.0",
+			"description": "Events published to RabbitMQ after every successful item change.",
+		},
+		"defaultContentType": "application/json",
+		"servers": map[string]any{
+			"broker": map[string]any{"host": brokerAddr(cfg.URL), "protocol": protocol},
+		},
+		"channels": map[string]any{"itemEvents": channel},
+		"operations": map[string]any{
+			"publishItemEvents": map[string]any{
+				"action":   "send",
+				"channel":  map[string]any{"$ref": "#/channels/itemEvents"},
+				"messages": messageRefs,
+				"bindings": map[string]any{"amqp": map[string]any{"deliveryMode": 2, "mandatory": false, "bindingVersion": "0.3.0"}},
+			},
+		},
+		"components": map[string]any{"schemas": map[string]any(schemas)},
+	}
+}
+
+// messageName is the component name of an event type's message, e.g. itemCreated
+func messageName(eventType events.EventType) string {
+	parts := strings.Split(string(eventType), ".")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+// getAsyncAPI serves the AsyncAPI document of the event stream
+func getAsyncAPI(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(asyncAPIDocument)
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Audited item operations
+const (
+	AuditOpCreate  = "item.create"
+	AuditOpUpdate  = "item.update"
+	AuditOpDelete  = "item.delete"
+	AuditOpRestore = "item.restore"
+	AuditOpPurge   = "item.purge"
+)
+
+// systemActor is the actor of operations the server performs on its own
+const systemActor = "system"
+
+// Outcomes recorded on audit records
+const (
+	AuditSuccess  = "success"
+	AuditDenied   = "denied"
+	AuditRejected = "rejected"
+)
+
+// auditOperations maps mutating routes to the operation audited for them
+var auditOperations = map[string]string{
+	"/items/add":    AuditOpCreate,
+	"/items/update": AuditOpUpdate,
+	"/items/delete": AuditOpDelete,
+	// restore is matched by route pattern
+	"/items/{id}/restore": AuditOpRestore,
+}
+
+// AuditRecord is one entry of the audit log. Hash covers every other field,
+// including PrevHash, so editing, removing or reordering records breaks the
+// chain.
+type AuditRecord struct {
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	SourceIP  string    `json:"sourceIp,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	Operation string    `json:"operation"`
+	ItemID    int       `json:"itemId,omitempty"`
+	Before    *Item     `json:"before,omitempty"`
+	After     *Item     `json:"after,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Detail    string    `json:"detail,omitempty"`
+	PrevHash  string    `json:"prevHash"`
+	Hash      string    `json:"hash"`
+}
+
+// computeHash returns the chain hash of the record with Hash cleared
+func (rec AuditRecord) computeHash() string {
+	rec.Hash = ""
+	data, _ := json.Marshal(rec)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditLog is an append-only, hash-chained log of item mutations, kept in
+// memory and optionally appended to an NDJSON file
+type AuditLog struct {
+	mu      sync.RWMutex
+	records []AuditRecord
+	file    *os.File
+	now     func() time.Time
+}
+
+// NewAuditLog opens the log at path, verifying and continuing any existing
+// chain; an empty path keeps the log in memory only
+func NewAuditLog(path string) (*AuditLog, error) {
+	l := &AuditLog{now: time.Now}
+	if path == "" {
+		return l, nil
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	records, err := readAuditChain(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to verify audit log: %w", err)
+	}
+	l.records = records
+	l.file = f
+	return l, nil
+}
+
+// Append completes the record's sequence number, time and hashes and adds it
+// to the log
+func (l *AuditLog) Append(rec AuditRecord) (AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec.Seq = int64(len(l.records)) + 1
+	rec.Time = l.now().UTC()
+	if n := len(l.records); n > 0 {
+		rec.PrevHash = l.records[n-1].Hash
+	}
+	rec.Hash = rec.computeHash()
+	if l.file != nil {
+		data, _ := json.Marshal(rec)
+		if _, err := l.file.Write(append(data, '\n')); err != nil {
+			return AuditRecord{}, fmt.Errorf("failed to write audit record: %w", err)
+		}
+	}
+	l.records = append(l.records, rec)
+	return rec, nil
+}
+
+// AuditFilter selects audit records; zero fields match everything
+type AuditFilter struct {
+	Tenant    string
+	Actor     string
+	Operation string
+	Outcome   string
+	ItemID    int
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+func (f AuditFilter) match(rec AuditRecord) bool {
+	return (f.Tenant == "" || rec.Tenant == f.Tenant) &&
+		(f.Actor == "" || rec.Actor == f.Actor) &&
+		(f.Operation == "" || rec.Operation == f.Operation) &&
+		(f.Outcome == "" || rec.Outcome == f.Outcome) &&
+		(f.ItemID == 0 || rec.ItemID == f.ItemID) &&
+		(f.Since.IsZero() || !rec.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || rec.Time.Before(f.Until))
+}
+
+// Query returns matching records in sequence order
+func (l *AuditLog) Query(f AuditFilter) []AuditRecord {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	matched := []AuditRecord{}
+	for _, rec := range l.records {
+		if f.match(rec) {
+			matched = append(matched, rec)
+			if f.Limit > 0 && len(matched) == f.Limit {
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// Close closes the backing file
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// readAuditChain decodes NDJSON audit records and checks their sequence
+// numbers and hash chain
+func readAuditChain(r io.Reader) ([]AuditRecord, error) {
+	var records []AuditRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	prevHash := ""
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return records, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rec.Seq != int64(len(records))+1 {
+			return records, fmt.Errorf("line %d: expected seq %d; got %d", line, len(records)+1, rec.Seq)
+		}
+		if rec.PrevHash != prevHash {
+			return records, fmt.Errorf("record %d: chain broken, prevHash does not match record %d", rec.Seq, rec.Seq-1)
+		}
+		if rec.computeHash() != rec.Hash {
+			return records, fmt.Errorf("record %d: hash mismatch, record was modified", rec.Seq)
+		}
+		prevHash = rec.Hash
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return records, err
+	}
+	return records, nil
+}
+
+// VerifyAuditLog checks an NDJSON audit log and returns the number of
+// records in the intact chain
+func VerifyAuditLog(r io.Reader) (int, error) {
+	records, err := readAuditChain(r)
+	return len(records), err
+}
+
+// auditLog is the process-wide audit log
+var auditLog, _ = NewAuditLog("")
+
+// sourceIP returns the client address of the request's connection
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditItem records an item operation attempted by the request
+func auditItem(r *http.Request, tenant, op string, itemID int, before, after *Item, outcome, detail string) {
+	rec := AuditRecord{
+		Tenant:    tenant,
+		Actor:     actorFromContext(r.Context()),
+		SourceIP:  sourceIP(r),
+		RequestID: requestIDFromContext(r.Context()),
+		Operation: op,
+		ItemID:    itemID,
+		Before:    before,
+		After:     after,
+		Outcome:   outcome,
+		Detail:    detail,
+	}
+	appendAudit(r.Context(), rec)
+}
+
+// auditRouteDenied records a request to a mutating route that was refused
+// before its handler ran, such as an unauthenticated or rate limited one.
+// Requests to other routes are not audited.
+func auditRouteDenied(r *http.Request, detail string) {
+	op, ok := auditOperations[r.URL.Path]
+	if !ok {
+		op, ok = auditOperations[r.Pattern]
+	}
+	if ok {
+		tenant, _ := resolveTenant(r)
+		auditItem(r, tenant, op, 0, nil, nil, AuditDenied, detail)
+	}
+}
+
+// auditSystemItem records an item operation the server performed on its own
+func auditSystemItem(ctx context.Context, tenant, op string, itemID int, before *Item) {
+	appendAudit(ctx, AuditRecord{
+		Tenant:    tenant,
+		Actor:     systemActor,
+		Operation: op,
+		ItemID:    itemID,
+		Before:    before,
+		Outcome:   AuditSuccess,
+	})
+}
+
+// appendAudit appends rec to the audit log and counts it by outcome
+func appendAudit(ctx context.Context, rec AuditRecord) {
+	if _, err := auditLog.Append(rec); err != nil {
+		auditRecordsTotal.Inc("error")
+		slog.ErrorContext(ctx, "failed to write audit record", "operation", rec.Operation, "error", err)
+		return
+	}
+	auditRecordsTotal.Inc(rec.Outcome)
+}
+
+// auditFilterFromQuery parses the /audit query parameters
+func auditFilterFromQuery(r *http.Request) (AuditFilter, error) {
+	q := r.URL.Query()
+	f := AuditFilter{
+		Tenant:    q.Get("tenant"),
+		Actor:     q.Get("actor"),
+		Operation: q.Get("operation"),
+		Outcome:   q.Get("outcome"),
+	}
+	var err error
+	if v := q.Get("item"); v != "" {
+		if f.ItemID, err = strconv.Atoi(v); err != nil {
+			return f, errors.New("item must be an integer")
+		}
+	}
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, errors.New("since must be an RFC 3339 timestamp")
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, errors.New("until must be an RFC 3339 timestamp")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			return f, errors.New("limit must be a non-negative integer")
+		}
+	}
+	// A caller bound to a tenant only sees that tenant's records
+	if p := principalFromContext(r.Context()); p != nil && p.Tenant != "" {
+		f.Tenant = p.Tenant
+	}
+	return f, nil
+}
+
+// getAudit lists audit records as a JSON array, or as NDJSON when requested
+// with ?format=ndjson or "Accept: application/x-ndjson"
+func getAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	records := auditLog.Query(filter)
+	if r.URL.Query().Get("format") == "ndjson" || r.Header.Get("Accept") == "application/x-ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.ndjson"`)
+		enc := json.NewEncoder(w)
+		for _, rec := range records {
+			enc.Encode(rec)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
+
+// runVerifyAudit implements the "verify-audit <file>" command
+func runVerifyAudit(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "usage: verify-audit <file|->")
+		return 2
+	}
+	var in io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+	n, err := VerifyAuditLog(in)
+	if err != nil {
+		fmt.Fprintf(stderr, "Audit log verification failed after %d intact records: %v\n", n, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Audit log OK: %d records\n", n)
+	return 0
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// useAuditLog installs a fresh in-memory audit log for the test
+func useAuditLog(t *testing.T) *AuditLog {
+	t.Helper()
+	previous := auditLog
+	auditLog, _ = NewAuditLog("")
+	t.Cleanup(func() { auditLog = previous })
+	return auditLog
+}
+
+// exportAudit encodes the log's records as NDJSON
+func exportAudit(l *AuditLog) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range l.Query(AuditFilter{}) {
+		enc.Encode(rec)
+	}
+	return buf.Bytes()
+}
+
+func TestAuditLogChain(t *testing.T) {
+	// Arrange
+	l, _ := NewAuditLog("")
+	l.Append(AuditRecord{Operation: AuditOpCreate, ItemID: 1, After: &Item{ID: 1, Name: "a"}, Outcome: AuditSuccess})
+	l.Append(AuditRecord{Operation: AuditOpUpdate, ItemID: 1, Before: &Item{ID: 1, Name: "a"}, After: &Item{ID: 1, Name: "b"}, Outcome: AuditSuccess})
+	l.Append(AuditRecord{Operation: AuditOpDelete, ItemID: 1, Before: &Item{ID: 1, Name: "b"}, Outcome: AuditSuccess})
+	export := exportAudit(l)
+
+	t.Run("Intact", func(t *testing.T) {
+		n, err := VerifyAuditLog(bytes.NewReader(export))
+		if err != nil || n != 3 {
+			t.Errorf("Expected 3 verified records; got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("ModifiedRecord", func(t *testing.T) {
+		tampered := bytes.Replace(export, []byte(`"name":"b"`), []byte(`"name":"x"`), 1)
+		n, err := VerifyAuditLog(bytes.NewReader(tampered))
+		if err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+			t.Errorf("Expected hash mismatch; got %v", err)
+		}
+		if n != 1 {
+			t.Errorf("Expected 1 intact record before the tampered one; got %d", n)
+		}
+	})
+
+	t.Run("RemovedRecord", func(t *testing.T) {
+		lines := bytes.SplitAfter(export, []byte("\n"))
+		tampered := append(append([]byte{}, lines[0]...), lines[2]...)
+		if _, err := VerifyAuditLog(bytes.NewReader(tampered)); err == nil {
+			t.Error("Expected removed record to be detected")
+		}
+	})
+
+	t.Run("RewrittenChain", func(t *testing.T) {
+		lines := bytes.SplitAfter(export, []byte("\n"))
+		var rec AuditRecord
+		json.Unmarshal(lines[1], &rec)
+		rec.After.Name = "x"
+		rec.Hash = rec.computeHash()
+		data, _ := json.Marshal(rec)
+		tampered := append(append(append([]byte{}, lines[0]...), append(data, '\n')...), lines[2]...)
+		if _, err := VerifyAuditLog(bytes.NewReader(tampered)); err == nil {
+			t.Error("Expected a rehashed record to break the next link")
+		}
+	})
+}
+
+func TestAuditLogFile(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	l, err := NewAuditLog(path)
+	if err != nil {
+		t.Fatalf("Could not open audit log: %v", err)
+	}
+	l.Append(AuditRecord{Operation: AuditOpCreate, Outcome: AuditSuccess})
+	l.Close()
+
+	// Act
+	reopened, err := NewAuditLog(path)
+	if err != nil {
+		t.Fatalf("Could not reopen audit log: %v", err)
+	}
+	rec, _ := reopened.Append(AuditRecord{Operation: AuditOpDelete, Outcome: AuditSuccess})
+	reopened.Close()
+
+	// Assert
+	if rec.Seq != 2 || rec.PrevHash == "" {
+		t.Errorf("Expected the chain to continue after reopening; got %+v", rec)
+	}
+	f, _ := os.Open(path)
+	defer f.Close()
+	if n, err := VerifyAuditLog(f); err != nil || n != 2 {
+		t.Errorf("Expected 2 verified records; got %d (%v)", n, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, bytes.Replace(data, []byte(AuditOpDelete), []byte(AuditOpUpdate), 1), 0o600)
+	if _, err := NewAuditLog(path); err == nil {
+		t.Error("Expected a tampered audit log to be rejected on open")
+	}
+}
+
+func TestAuditItemOperations(t *testing.T) {
+	// Arrange
+	l := useAuditLog(t)
+	useStore(t, 0, Item{ID: 1, Name: "Old"})
+	eventPublisher = nil
+	mux := newMux()
+	do := func(method, path, body string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(TenantHeader, "acme")
+		req = req.WithContext(withRequestIDContext(req.Context(), "req-1"))
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Act
+	do(http.MethodPost, "/items/add", `{"name":"New"}`)
+	do(http.MethodPut, "/items/update", `{"id":1,"name":"Renamed"}`)
+	do(http.MethodPut, "/items/update", `not json`)
+	do(http.MethodDelete, "/items/delete", `{"id":1}`)
+
+	// Assert
+	records := l.Query(AuditFilter{})
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 audit records; got %+v", records)
+	}
+	created, updated, invalid, deleted := records[0], records[1], records[2], records[3]
+	if created.Operation != AuditOpCreate || created.Outcome != AuditSuccess || created.After == nil || created.After.Name != "New" {
+		t.Errorf("Unexpected create record: %+v", created)
+	}
+	if created.Tenant != "acme" || created.RequestID != "req-1" || created.SourceIP != "192.0.2.1" {
+		t.Errorf("Expected tenant, request ID and source IP; got %+v", created)
+	}
+	if updated.Outcome != AuditSuccess || updated.Before == nil || updated.Before.Name != "New" || updated.After.Name != "Renamed" {
+		t.Errorf("Unexpected update record: %+v", updated)
+	}
+	if invalid.Outcome != AuditRejected || invalid.Detail != "invalid input" {
+		t.Errorf("Unexpected rejected record: %+v", invalid)
+	}
+	if deleted.Operation != AuditOpDelete || deleted.Before == nil || deleted.After == nil || deleted.After.DeletedAt == nil {
+		t.Errorf("Unexpected delete record: %+v", deleted)
+	}
+}
+
+func TestAuditDeniedAttempt(t *testing.T) {
+	// Arrange
+	l := useAuditLog(t)
+	a := useAuthenticator(t, AuthConfig{Enabled: true})
+	key, plaintext, _ := a.keys.Create("reader", []string{RoleReader}, "")
+	useStore(t, 0)
+	req := httptest.NewRequest(http.MethodPost, "/items/add", strings.NewReader(`{"name":"x"}`))
+	req.Header.Set(APIKeyHeader, plaintext)
+
+	// Act
+	newMux().ServeHTTP(httptest.NewRecorder(), req)
+
+	// Assert
+	records := l.Query(AuditFilter{Outcome: AuditDenied})
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 denied record; got %+v", records)
+	}
+	if records[0].Actor != "apikey:"+key.ID || records[0].Operation != AuditOpCreate || records[0].Tenant != DefaultTenant {
+		t.Errorf("Unexpected denied record: %+v", records[0])
+	}
+}
+
+func TestAuditRefusedRequests(t *testing.T) {
+	// Arrange
+	l := useAuditLog(t)
+	useAuthenticator(t, AuthConfig{Enabled: true, BootstrapAPIKey: "bootstrap-secret"})
+	useRateLimiters(t, RateLimitByPrincipal, RateLimitConfig{ReadPerMinute: 60, ReadBurst: 100, WritePerMinute: 1, WriteBurst: 1, AuthFailuresPerMinute: 60, AuthFailureBurst: 100})
+	useStore(t, 0)
+	eventPublisher = nil
+	mux := newMux()
+	do := func(method, path, key string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"name":"x"}`))
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Act
+	codes := []int{
+		do(http.MethodGet, "/items", ""),
+		do(http.MethodPost, "/items/add", ""),
+		do(http.MethodPost, "/items/add", "bootstrap-secret"),
+		do(http.MethodPost, "/items/add", "bootstrap-secret"),
+	}
+
+	// Assert
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusCreated, http.StatusTooManyRequests}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("Expected request %d to get %d; got %d", i+1, want[i], codes[i])
+		}
+	}
+	records := l.Query(AuditFilter{Outcome: AuditDenied})
+	if len(records) != 2 {
+		t.Fatalf("Expected the refused mutations to be audited; got %+v", records)
+	}
+	for _, rec := range records {
+		if rec.Operation != AuditOpCreate || rec.Tenant != DefaultTenant {
+			t.Errorf("Unexpected denied record: %+v", rec)
+		}
+	}
+	if !slices.ContainsFunc(records, func(rec AuditRecord) bool {
+		return rec.Actor == "" && strings.HasPrefix(rec.Detail, "authentication failed")
+	}) {
+		t.Errorf("Expected an unauthenticated attempt; got %+v", records)
+	}
+	if !slices.ContainsFunc(records, func(rec AuditRecord) bool { return rec.Actor != "" && strings.Contains(rec.Detail, "rate limit") }) {
+		t.Errorf("Expected a rate limited attempt; got %+v", records)
+	}
+}
+
+func TestGetAudit(t *testing.T) {
+	// Arrange
+	l := useAuditLog(t)
+	l.Append(AuditRecord{Tenant: "acme", Actor: "alice", Operation: AuditOpCreate, ItemID: 1, Outcome: AuditSuccess})
+	l.Append(AuditRecord{Tenant: "acme", Actor: "bob", Operation: AuditOpDelete, ItemID: 1, Outcome: AuditDenied})
+	l.Append(AuditRecord{Tenant: "globex", Actor: "alice", Operation: AuditOpCreate, ItemID: 1, Outcome: AuditSuccess})
+	mux := newMux()
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run("Filters", func(t *testing.T) {
+		rec := get("/audit?actor=alice&tenant=acme")
+		var got []AuditRecord
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if len(got) != 1 || got[0].Seq != 1 {
+			t.Errorf("Expected only record 1; got %+v", got)
+		}
+	})
+
+	t.Run("InvalidFilter", func(t *testing.T) {
+		if rec := get("/audit?since=yesterday"); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status Bad Request; got %v", rec.Code)
+		}
+	})
+
+	t.Run("ExportNDJSON", func(t *testing.T) {
+		rec := get("/audit?format=ndjson")
+		if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Expected NDJSON content type; got %s", ct)
+		}
+		lines := 0
+		for scanner := bufio.NewScanner(bytes.NewReader(rec.Body.Bytes())); scanner.Scan(); {
+			lines++
+		}
+		if lines != 3 {
+			t.Errorf("Expected 3 lines; got %d", lines)
+		}
+		if n, err := VerifyAuditLog(rec.Body); err != nil || n != 3 {
+			t.Errorf("Expected the export to verify; got %d (%v)", n, err)
+		}
+	})
+}
+
+func TestRunVerifyAudit(t *testing.T) {
+	// Arrange
+	l, _ := NewAuditLog("")
+	l.Append(AuditRecord{Operation: AuditOpCreate, Outcome: AuditSuccess})
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	os.WriteFile(path, exportAudit(l), 0o600)
+	var stdout, stderr bytes.Buffer
+
+	// Act
+	code := runVerifyAudit([]string{path}, &stdout, &stderr)
+
+	// Assert
+	if code != 0 || !strings.Contains(stdout.String(), "1 records") {
+		t.Errorf("Expected success; got %d %q %q", code, stdout.String(), stderr.String())
+	}
+	os.WriteFile(path, []byte(`{"seq":1,"operation":"item.create","outcome":"success","prevHash":"","hash":"00"}`+"\n"), 0o600)
+	if code := runVerifyAudit([]string{path}, &stdout, &stderr); code != 1 {
+		t.Errorf("Expected exit code 1 for a tampered log; got %d", code)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKeyHeader is an alternative to "Authorization: Bearer <key>"
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyPrefix marks credentials issued by this server
+const apiKeyPrefix = "ik_"
+
+// Authentication methods recorded on a Principal
+const (
+	AuthMethodAPIKey = "api_key"
+	AuthMethodJWT    = "jwt"
+)
+
+// RoleAdmin is granted to the bootstrap API key
+const RoleAdmin = "admin"
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject string   `json:"subject"`
+	Method  string   `json:"method"`
+	Roles   []string `json:"roles,omitempty"`
+	// Tenant binds the principal to one tenant when set
+	Tenant string `json:"tenant,omitempty"`
+}
+
+type principalKey struct{}
+
+// withPrincipal returns a context carrying the authenticated principal
+func withPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// principalFromContext returns the authenticated principal, if any
+func principalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// actorFromContext names the caller recorded on events
+func actorFromContext(ctx context.Context) string {
+	if p := principalFromContext(ctx); p != nil {
+		return p.Subject
+	}
+	return ""
+}
+
+// APIKey is a stored API key; only the SHA-256 hash of the secret is kept
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Roles     []string  `json:"roles"`
+	Tenant    string    `json:"tenant,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// APIKeyStore holds hashed API keys, optionally persisted to a JSON file
+type APIKeyStore struct {
+	mu     sync.RWMutex
+	keys   map[string]APIKey // by hash
+	path   string
+	static map[string]bool // hashes that are not persisted
+}
+
+// NewAPIKeyStore loads keys from path when it is set and exists
+func NewAPIKeyStore(path string) (*APIKeyStore, error) {
+	s := &APIKeyStore{keys: make(map[string]APIKey), path: path, static: make(map[string]bool)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys file: %w", err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys file: %w", err)
+	}
+	for _, k := range keys {
+		s.keys[k.Hash] = k
+	}
+	return s, nil
+}
+
+// hashAPIKey returns the hex SHA-256 digest stored for a key
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// AddStatic registers a key supplied by configuration; it is never persisted
+func (s *APIKeyStore) AddStatic(name, key string, roles []string) {
+	hash := hashAPIKey(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[hash] = APIKey{ID: name, Name: name, Hash: hash, Roles: roles, CreatedAt: time.Now().UTC()}
+	s.static[hash] = true
+}
+
+// Create issues a new key, optionally bound to a tenant, and returns its
+// plaintext, which is not stored
+func (s *APIKeyStore) Create(name string, roles []string, tenant string) (APIKey, string, error) {
+	idBytes := make([]byte, 6)
+	secret := make([]byte, 32)
+	rand.Read(idBytes)
+	rand.Read(secret)
+	id := hex.EncodeToString(idBytes)
+	plaintext := apiKeyPrefix + id + "_" + hex.EncodeToString(secret)
+	key := APIKey{ID: id, Name: name, Hash: hashAPIKey(plaintext), Roles: roles, Tenant: tenant, CreatedAt: time.Now().UTC()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.Hash] = key
+	if err := s.saveLocked(); err != nil {
+		delete(s.keys, key.Hash)
+		return APIKey{}, "", err
+	}
+	return key, plaintext, nil
+}
+
+// Revoke deletes the key with the given ID. With tenant set, only a key
+// bound to that tenant is revoked.
+func (s *APIKeyStore) Revoke(id, tenant string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, k := range s.keys {
+		if k.ID == id && !s.static[hash] && (tenant == "" || k.Tenant == tenant) {
+			delete(s.keys, hash)
+			return true, s.saveLocked()
+		}
+	}
+	return false, nil
+}
+
+// Lookup returns the key matching plaintext
+func (s *APIKeyStore) Lookup(plaintext string) (APIKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[hashAPIKey(plaintext)]
+	return k, ok
+}
+
+// List returns all keys sorted by creation time
+func (s *APIKeyStore) List() []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys
+}
+
+func (s *APIKeyStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	keys := make([]APIKey, 0, len(s.keys))
+	for hash, k := range s.keys {
+		if !s.static[hash] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Authenticator resolves request credentials to a Principal
+type Authenticator struct {
+	keys        *APIKeyStore
+	jwt         *JWTVerifier
+	clientCerts map[string]CertPrincipal
+}
+
+// NewAuthenticator builds an authenticator from configuration
+func NewAuthenticator(cfg AuthConfig) (*Authenticator, error) {
+	keys, err := NewAPIKeyStore(cfg.APIKeysFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.BootstrapAPIKey != "" {
+		keys.AddStatic("bootstrap", cfg.BootstrapAPIKey, []string{RoleAdmin})
+	}
+	verifier, err := NewJWTVerifier(cfg.JWT)
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{keys: keys, jwt: verifier, clientCerts: cfg.ClientCerts}, nil
+}
+
+// errNoCredentials is returned when the request carries no credentials
+var errNoCredentials = errors.New("no credentials")
+
+// Authenticate resolves the request's API key or bearer token, falling back
+// to a verified TLS client certificate
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	credential := r.Header.Get(APIKeyHeader)
+	if credential == "" {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+				return principalFromCert(r.TLS.VerifiedChains[0][0], a.clientCerts), nil
+			}
+			return nil, errNoCredentials
+		}
+		credential = strings.TrimSpace(token)
+		if looksLikeJWT(credential) {
+			if a.jwt == nil {
+				return nil, errors.New("bearer tokens are not accepted")
+			}
+			claims, err := a.jwt.Verify(credential)
+			if err != nil {
+				return nil, err
+			}
+			return &Principal{Subject: claims.Subject, Method: AuthMethodJWT, Roles: claims.Roles, Tenant: claims.Tenant}, nil
+		}
+	}
+	key, ok := a.keys.Lookup(credential)
+	if !ok {
+		return nil, errors.New("unknown API key")
+	}
+	return &Principal{Subject: "apikey:" + key.ID, Method: AuthMethodAPIKey, Roles: key.Roles, Tenant: key.Tenant}, nil
+}
+
+// authenticator is nil when authentication is disabled
+var authenticator *Authenticator
+
+// authenticate rejects requests without valid credentials and attaches the
+// principal to the request context; it passes every request through when
+// authentication is disabled. Client IPs that failed too often get 429
+// instead of 401 for further failures.
+func authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authenticator == nil {
+			next(w, r)
+			return
+		}
+		principal, err := authenticator.Authenticate(r)
+		if err != nil {
+			slog.InfoContext(r.Context(), "authentication failed", "error", err)
+			if throttleAuthFailure(w, r) {
+				auditRouteDenied(r, "too many failed authentication attempts")
+				return
+			}
+			auditRouteDenied(r, "authentication failed: "+err.Error())
+			w.Header().Set("WWW-Authenticate", `Bearer realm="items"`)
+			writeProblem(w, r, http.StatusUnauthorized, "valid API key or bearer token required")
+			return
+		}
+		next(w, r.WithContext(withPrincipal(r.Context(), principal)))
+	}
+}
+
+// requireAuthenticator answers 404 when authentication is disabled, for
+// routes that only exist alongside the API key store
+func requireAuthenticator(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authenticator == nil {
+			writeProblem(w, r, http.StatusNotFound, "API key administration requires authentication to be enabled")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// apiKeyView is the API key representation returned by admin endpoints
+type apiKeyView struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Roles     []string  `json:"roles"`
+	Tenant    string    `json:"tenant,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Key       string    `json:"key,omitempty"`
+}
+
+// principalTenant returns the tenant the caller is bound to, or "" when it
+// may act on every tenant
+func principalTenant(ctx context.Context) string {
+	if p := principalFromContext(ctx); p != nil {
+		return p.Tenant
+	}
+	return ""
+}
+
+// listAPIKeys lists the keys, only those of its tenant for a caller bound
+// to one
+func listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tenant := principalTenant(r.Context())
+	views := []apiKeyView{}
+	for _, k := range authenticator.keys.List() {
+		if tenant != "" && k.Tenant != tenant {
+			continue
+		}
+		views = append(views, apiKeyView{ID: k.ID, Name: k.Name, Roles: k.Roles, Tenant: k.Tenant, CreatedAt: k.CreatedAt})
+	}
+	json.NewEncoder(w).Encode(views)
+}
+
+func createAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req struct {
+		Name   string   `json:"name"`
+		Roles  []string `json:"roles"`
+		Tenant string   `json:"tenant"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	// A caller bound to a tenant only issues keys bound to that tenant
+	if tenant := principalTenant(r.Context()); tenant != "" {
+		if req.Tenant != "" && req.Tenant != tenant {
+			writeProblem(w, r, http.StatusForbidden, fmt.Sprintf("credentials are not valid for tenant %q", req.Tenant))
+			return
+		}
+		req.Tenant = tenant
+	}
+	if req.Tenant != "" && !tenantIDPattern.MatchString(req.Tenant) {
+		http.Error(w, "Invalid tenant", http.StatusBadRequest)
+		return
+	}
+	key, plaintext, err := authenticator.keys.Create(req.Name, req.Roles, req.Tenant)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to create API key", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "API key created", "key_id", key.ID, "actor", actorFromContext(r.Context()))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(apiKeyView{ID: key.ID, Name: key.Name, Roles: key.Roles, Tenant: key.Tenant, CreatedAt: key.CreatedAt, Key: plaintext})
+}
+
+func revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if req.ID == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	found, err := authenticator.keys.Revoke(req.ID, principalTenant(r.Context()))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to revoke API key", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	slog.InfoContext(r.Context(), "API key revoked", "key_id", req.ID, "actor", actorFromContext(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
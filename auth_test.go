@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useAuthenticator enables authentication for the duration of a test
+func useAuthenticator(t *testing.T, cfg AuthConfig) *Authenticator {
+	t.Helper()
+	a, err := NewAuthenticator(cfg)
+	if err != nil {
+		t.Fatalf("Could not create authenticator: %v", err)
+	}
+	previous := authenticator
+	authenticator = a
+	t.Cleanup(func() { authenticator = previous })
+	return a
+}
+
+func TestAPIKeyStore(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store, err := NewAPIKeyStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Act
+	key, plaintext, err := store.Create("ci", []string{"editor"}, "")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(plaintext, apiKeyPrefix) || key.Hash == plaintext {
+		t.Errorf("Unexpected key material: %q / %+v", plaintext, key)
+	}
+	reloaded, err := NewAPIKeyStore(path)
+	if err != nil {
+		t.Fatalf("Could not reload store: %v", err)
+	}
+	if got, ok := reloaded.Lookup(plaintext); !ok || got.ID != key.ID {
+		t.Error("Expected key to be persisted and found after reload")
+	}
+	if _, ok := reloaded.Lookup(plaintext + "x"); ok {
+		t.Error("Expected wrong key to be rejected")
+	}
+	if found, err := reloaded.Revoke(key.ID, ""); !found || err != nil {
+		t.Errorf("Expected revoke to succeed; got %v, %v", found, err)
+	}
+	if _, ok := reloaded.Lookup(plaintext); ok {
+		t.Error("Expected revoked key to be rejected")
+	}
+}
+
+func TestAuthenticateMiddleware(t *testing.T) {
+	a := useAuthenticator(t, AuthConfig{Enabled: true, JWT: JWTConfig{HMACSecret: "secret"}})
+	_, plaintext, _ := a.keys.Create("reader", []string{"reader"}, "")
+	var seen *Principal
+	handler := authenticate(func(w http.ResponseWriter, r *http.Request) {
+		seen = principalFromContext(r.Context())
+	})
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+		wantMethod string
+	}{
+		{"NoCredentials", "", "", http.StatusUnauthorized, ""},
+		{"APIKeyHeader", APIKeyHeader, plaintext, http.StatusOK, AuthMethodAPIKey},
+		{"APIKeyBearer", "Authorization", "Bearer " + plaintext, http.StatusOK, AuthMethodAPIKey},
+		{"UnknownAPIKey", APIKeyHeader, "ik_nope", http.StatusUnauthorized, ""},
+		{"ValidJWT", "Authorization", "Bearer " + signTestJWT(t, "HS256", []byte("secret"), validClaims()), http.StatusOK, AuthMethodJWT},
+		{"InvalidJWT", "Authorization", "Bearer " + signTestJWT(t, "HS256", []byte("wrong"), validClaims()), http.StatusUnauthorized, ""},
+		{"BasicScheme", "Authorization", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			req := httptest.NewRequest(http.MethodGet, "/items", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d; got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate challenge")
+			}
+			if tt.wantMethod != "" && (seen == nil || seen.Method != tt.wantMethod) {
+				t.Errorf("Expected principal authenticated by %s; got %+v", tt.wantMethod, seen)
+			}
+		})
+	}
+}
+
+func TestAuthenticateDisabled(t *testing.T) {
+	authenticator = nil
+	called := false
+	handler := authenticate(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	if !called {
+		t.Error("Expected request to pass through when authentication is disabled")
+	}
+}
+
+func TestAPIKeyAdminEndpointsAuthDisabled(t *testing.T) {
+	previous := authenticator
+	authenticator = nil
+	t.Cleanup(func() { authenticator = previous })
+	mux := newMux()
+
+	tests := []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/admin/api-keys"},
+		{http.MethodPost, "/admin/api-keys"},
+		{http.MethodDelete, "/admin/api-keys/revoke"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"id":"x","name":"ci","roles":["admin"]}`)))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("Expected status 404 without authentication; got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAdminEndpoints(t *testing.T) {
+	// Arrange
+	useAuthenticator(t, AuthConfig{Enabled: true, BootstrapAPIKey: "bootstrap-secret"})
+	mux := newMux()
+	do := func(method, path, key string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Act: create a key as admin
+	rec := do(http.MethodPost, "/admin/api-keys", "bootstrap-secret", map[string]any{"name": "ci", "roles": []string{"editor"}})
+
+	// Assert
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status Created; got %v: %s", rec.Code, rec.Body.String())
+	}
+	var created apiKeyView
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.Key == "" || created.ID == "" {
+		t.Fatalf("Expected plaintext key in create response; got %+v", created)
+	}
+
+	if rec := do(http.MethodGet, "/items", created.Key, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected new key to authenticate; got %v", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/admin/api-keys", created.Key, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected non-admin key to be forbidden; got %v", rec.Code)
+	}
+
+	rec = do(http.MethodGet, "/admin/api-keys", "bootstrap-secret", nil)
+	if strings.Contains(rec.Body.String(), created.Key) || strings.Contains(rec.Body.String(), "hash") {
+		t.Errorf("List must not expose key material: %s", rec.Body.String())
+	}
+
+	if rec := do(http.MethodDelete, "/admin/api-keys/revoke", "bootstrap-secret", map[string]string{"id": created.ID}); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected revoke to succeed; got %v", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/items", created.Key, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked key to be rejected; got %v", rec.Code)
+	}
+}
+
+func TestAPIKeyAdminEndpointsTenantScope(t *testing.T) {
+	// Arrange
+	a := useAuthenticator(t, AuthConfig{Enabled: true})
+	_, acmeAdmin, _ := a.keys.Create("acme-admin", []string{RoleAdmin}, "acme")
+	globex, _, _ := a.keys.Create("globex-ci", []string{"editor"}, "globex")
+	mux := newMux()
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set(APIKeyHeader, acmeAdmin)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("CreateBindsToCallerTenant", func(t *testing.T) {
+		rec := do(http.MethodPost, "/admin/api-keys", map[string]any{"name": "x", "roles": []string{RoleAdmin}})
+		var created apiKeyView
+		json.NewDecoder(rec.Body).Decode(&created)
+		if rec.Code != http.StatusCreated || created.Tenant != "acme" {
+			t.Errorf("Expected a key bound to acme; got %v %+v", rec.Code, created)
+		}
+	})
+
+	t.Run("CreateForOtherTenant", func(t *testing.T) {
+		rec := do(http.MethodPost, "/admin/api-keys", map[string]any{"name": "x", "roles": []string{RoleAdmin}, "tenant": "globex"})
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden; got %v", rec.Code)
+		}
+	})
+
+	t.Run("ListOwnTenant", func(t *testing.T) {
+		var keys []apiKeyView
+		json.NewDecoder(do(http.MethodGet, "/admin/api-keys", nil).Body).Decode(&keys)
+		for _, k := range keys {
+			if k.Tenant != "acme" {
+				t.Errorf("Expected only keys of acme; got %+v", k)
+			}
+		}
+	})
+
+	t.Run("RevokeOtherTenant", func(t *testing.T) {
+		rec := do(http.MethodDelete, "/admin/api-keys/revoke", map[string]string{"id": globex.ID})
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected status NotFound; got %v", rec.Code)
+		}
+		if len(a.keys.List()) != 3 {
+			t.Errorf("Expected the key of globex to be kept; got %+v", a.keys.List())
+		}
+	})
+}
+
+func TestActorFromContext(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	if got := actorFromContext(req.Context()); got != "" {
+		t.Errorf("Expected empty actor; got %q", got)
+	}
+	ctx := withPrincipal(req.Context(), &Principal{Subject: "alice"})
+	if got := actorFromContext(ctx); got != "alice" {
+		t.Errorf("Expected actor alice; got %q", got)
+	}
+}
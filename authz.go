@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+)
+
+// Permissions checked by authorize
+const (
+	PermItemsRead     = "items:read"
+	PermItemsWrite    = "items:write"
+	PermItemsDelete   = "items:delete"
+	PermAPIKeysManage = "apikeys:manage"
+	PermAuditRead     = "audit:read"
+	// PermTenantsSelect lets callers not bound to a tenant pick any tenant
+	// with X-Tenant-ID
+	PermTenantsSelect = "tenants:select"
+	// PermAll grants every permission
+	PermAll = "*"
+)
+
+// knownPermissions lists every permission a role may be granted
+var knownPermissions = map[string]bool{
+	PermItemsRead:     true,
+	PermItemsWrite:    true,
+	PermItemsDelete:   true,
+	PermAPIKeysManage: true,
+	PermAuditRead:     true,
+	PermTenantsSelect: true,
+	PermAll:           true,
+}
+
+// Built-in role names used by the default policy
+const (
+	RoleReader = "reader"
+	RoleEditor = "editor"
+)
+
+// defaultRoles is the policy used when the configuration defines none
+func defaultRoles() map[string][]string {
+	return map[string][]string{
+		RoleReader: {PermItemsRead},
+		RoleEditor: {PermItemsRead, PermItemsWrite},
+		RoleAdmin:  {PermAll},
+	}
+}
+
+// Policy maps roles to the permissions they grant
+type Policy struct {
+	roles map[string]map[string]bool
+}
+
+// NewPolicy builds a policy from configuration, rejecting unknown permissions
+func NewPolicy(cfg AuthzConfig) (*Policy, error) {
+	p := &Policy{roles: make(map[string]map[string]bool, len(cfg.Roles))}
+	for role, perms := range cfg.Roles {
+		granted := make(map[string]bool, len(perms))
+		for _, perm := range perms {
+			if !knownPermissions[perm] {
+				return nil, fmt.Errorf("role %q grants unknown permission %q", role, perm)
+			}
+			granted[perm] = true
+		}
+		p.roles[role] = granted
+	}
+	return p, nil
+}
+
+// Allowed reports whether any of the principal's roles grants perm
+func (p *Policy) Allowed(principal *Principal, perm string) bool {
+	if principal == nil {
+		return false
+	}
+	for _, role := range principal.Roles {
+		if granted := p.roles[role]; granted[perm] || granted[PermAll] {
+			return true
+		}
+	}
+	return false
+}
+
+// policy is the active authorization policy
+var policy, _ = NewPolicy(AuthzConfig{Roles: defaultRoles()})
+
+// authorize rejects authenticated callers lacking perm with a 403 problem
+// response; it passes every request through when authentication is disabled
+func authorize(perm string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authenticator == nil {
+			next(w, r)
+			return
+		}
+		principal := principalFromContext(r.Context())
+		if !policy.Allowed(principal, perm) {
+			recordAuthorizationDenied(r.Context(), principal, perm, r)
+			writeProblem(w, r, http.StatusForbidden, "missing permission "+perm)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// recordAuthorizationDenied logs a rejected request and, for item
+// mutations, appends it to the audit log
+func recordAuthorizationDenied(ctx context.Context, principal *Principal, perm string, r *http.Request) {
+	authorizationDeniedTotal.Inc(perm)
+	var subject string
+	var roles []string
+	if principal != nil {
+		subject = principal.Subject
+		roles = append(roles, principal.Roles...)
+		sort.Strings(roles)
+	}
+	slog.WarnContext(ctx, "authorization denied",
+		"audit", true,
+		"actor", subject,
+		"roles", roles,
+		"permission", perm,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"remote_addr", r.RemoteAddr,
+	)
+	auditRouteDenied(r, "missing permission "+perm)
+}
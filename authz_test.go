@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPolicyAllowed(t *testing.T) {
+	p, err := NewPolicy(AuthzConfig{Roles: defaultRoles()})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tests := []struct {
+		roles []string
+		perm  string
+		want  bool
+	}{
+		{[]string{RoleReader}, PermItemsRead, true},
+		{[]string{RoleReader}, PermItemsWrite, false},
+		{[]string{RoleEditor}, PermItemsWrite, true},
+		{[]string{RoleEditor}, PermItemsDelete, false},
+		{[]string{RoleAdmin}, PermItemsDelete, true},
+		{[]string{RoleAdmin}, PermAPIKeysManage, true},
+		{[]string{"unknown"}, PermItemsRead, false},
+		{nil, PermItemsRead, false},
+	}
+	for _, tt := range tests {
+		if got := p.Allowed(&Principal{Roles: tt.roles}, tt.perm); got != tt.want {
+			t.Errorf("Allowed(%v, %s) = %v; want %v", tt.roles, tt.perm, got, tt.want)
+		}
+	}
+	if p.Allowed(nil, PermItemsRead) {
+		t.Error("Expected nil principal to be denied")
+	}
+}
+
+func TestNewPolicyRejectsUnknownPermission(t *testing.T) {
+	if _, err := NewPolicy(AuthzConfig{Roles: map[string][]string{"x": {"items:explode"}}}); err == nil {
+		t.Error("Expected error for unknown permission")
+	}
+}
+
+func TestAuthorizeThroughRoutes(t *testing.T) {
+	// Arrange
+	a := useAuthenticator(t, AuthConfig{Enabled: true})
+	_, readerKey, _ := a.keys.Create("reader", []string{RoleReader}, "")
+	_, editorKey, _ := a.keys.Create("editor", []string{RoleEditor}, "")
+	items := useStore(t, 0, Item{ID: 1, Name: "Existing"})
+	eventPublisher = nil
+	mux := newMux()
+	do := func(method, path, key string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	deniedBefore := authorizationDeniedTotal.Value(PermItemsDelete)
+
+	// Act & Assert
+	if rec := do(http.MethodGet, "/items", readerKey, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected reader to list items; got %v", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/items/add", readerKey, Item{Name: "x"}); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected reader to be forbidden from adding; got %v", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/items/add", editorKey, Item{Name: "x"}); rec.Code != http.StatusCreated {
+		t.Errorf("Expected editor to add; got %v", rec.Code)
+	}
+
+	rec := do(http.MethodDelete, "/items/delete", editorKey, Item{ID: 1})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected editor to be forbidden from deleting; got %v", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected problem response; got %s", ct)
+	}
+	var problem Problem
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+		t.Fatalf("Could not decode problem: %v", err)
+	}
+	if problem.Status != http.StatusForbidden || problem.Instance != "/items/delete" {
+		t.Errorf("Unexpected problem: %+v", problem)
+	}
+	if got := authorizationDeniedTotal.Value(PermItemsDelete); got != deniedBefore+1 {
+		t.Errorf("Expected denial to be counted; got %v -> %v", deniedBefore, got)
+	}
+	if len(items.items) != 2 {
+		t.Errorf("Expected denied delete to leave items untouched; got %v", items.items)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net"
+	"net/url"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+// connection returns the settings used to dial the broker, shared with
+// event consumers through the events package
+func (c BrokerConfig) connection() events.ConnectionConfig {
+	return events.ConnectionConfig{
+		URL:          c.URL,
+		Username:     c.Username,
+		Password:     c.Password,
+		PasswordFile: c.PasswordFile,
+		TLS: events.TLSConfig{
+			CAFile:     c.TLS.CAFile,
+			CertFile:   c.TLS.CertFile,
+			KeyFile:    c.TLS.KeyFile,
+			ServerName: c.TLS.ServerName,
+		},
+		Heartbeat:      c.Heartbeat.Duration,
+		Locale:         c.Locale,
+		ConnectionName: c.ConnectionName,
+	}
+}
+
+// dialBroker connects to RabbitMQ with the configured TLS, credentials and
+// connection settings. Errors never include the broker's credentials.
+func dialBroker(cfg BrokerConfig) (*amqp.Connection, error) {
+	return events.Dial(cfg.connection())
+}
+
+// brokerAddr is the broker's host and port, safe to log
+func brokerAddr(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = "5672"
+		if u.Scheme == "amqps" {
+			port = "5671"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
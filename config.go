@@ -0,0 +1,579 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+// Duration is a time.Duration that reads and writes JSON as "5s"-style strings
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// Config is the complete server configuration
+type Config struct {
+	Server    ServerConfig    `json:"server"`
+	Broker    BrokerConfig    `json:"broker"`
+	Store     StoreConfig     `json:"store"`
+	Log       LogConfig       `json:"log"`
+	Tracing   TracingConfig   `json:"tracing"`
+	Auth      AuthConfig      `json:"auth"`
+	Authz     AuthzConfig     `json:"authz"`
+	Audit     AuditConfig     `json:"audit"`
+	RateLimit RateLimitConfig `json:"rateLimit"`
+}
+
+// ServerConfig configures the HTTP listener
+type ServerConfig struct {
+	Addr              string   `json:"addr"`
+	ShutdownTimeout   Duration `json:"shutdownTimeout"`
+	ReadHeaderTimeout Duration `json:"readHeaderTimeout"`
+	ReadTimeout       Duration `json:"readTimeout"`
+	WriteTimeout      Duration `json:"writeTimeout"`
+	IdleTimeout       Duration `json:"idleTimeout"`
+	// MaxBodyBytes caps request bodies; 0 disables the limit
+	MaxBodyBytes int `json:"maxBodyBytes"`
+	// MaxConcurrentRequests sheds load above this many requests; 0 disables shedding
+	MaxConcurrentRequests int       `json:"maxConcurrentRequests"`
+	TLS                   TLSConfig `json:"tls"`
+}
+
+// TLSConfig configures HTTPS and client certificate verification
+type TLSConfig struct {
+	// CertFile and KeyFile enable TLS when set
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// ClientCAFile is the CA bundle client certificates are verified against
+	ClientCAFile string `json:"clientCaFile"`
+	// ClientAuth is none, optional (verify if presented) or require
+	ClientAuth string `json:"clientAuth"`
+	// ReloadInterval is how often the files are checked for changes
+	ReloadInterval Duration `json:"reloadInterval"`
+}
+
+// BrokerConfig configures the RabbitMQ connection and topology
+type BrokerConfig struct {
+	URL      string `json:"url"`
+	Exchange string `json:"exchange"`
+	Queue    string `json:"queue"`
+	// DeadLetterExchange receives events consumers reject; empty discards
+	// them
+	DeadLetterExchange string `json:"deadLetterExchange"`
+	// DeadLetterQueue collects dead-lettered events; empty uses the queue
+	// name with a ".dead-letter" suffix
+	DeadLetterQueue string `json:"deadLetterQueue"`
+	// Required makes a missing broker fail readiness
+	Required bool `json:"required"`
+	// Username, Password and PasswordFile override credentials in the URL
+	Username     string          `json:"username"`
+	Password     string          `json:"password"`
+	PasswordFile string          `json:"passwordFile"`
+	TLS          BrokerTLSConfig `json:"tls"`
+	// Heartbeat is the negotiated AMQP heartbeat interval
+	Heartbeat      Duration `json:"heartbeat"`
+	Locale         string   `json:"locale"`
+	ConnectionName string   `json:"connectionName"`
+	// UnknownSchemaVersion is what consumers do with events newer than they
+	// understand: reject or pass
+	UnknownSchemaVersion string `json:"unknownSchemaVersion"`
+}
+
+// BrokerTLSConfig configures TLS for amqps:// URLs
+type BrokerTLSConfig struct {
+	// CAFile is the CA bundle the broker certificate is verified against;
+	// empty uses the system roots
+	CAFile   string `json:"caFile"`
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// ServerName overrides the host name verified in the broker certificate
+	ServerName string `json:"serverName"`
+}
+
+// StoreConfig configures the item store
+type StoreConfig struct {
+	LockTimeout Duration `json:"lockTimeout"`
+	// MaxItemsPerTenant caps each tenant's items; 0 means unlimited
+	MaxItemsPerTenant int `json:"maxItemsPerTenant"`
+	// MinSequenceTimeout bounds how long reads wait for min-sequence
+	MinSequenceTimeout Duration `json:"minSequenceTimeout"`
+	// TrashRetention is how long deleted items are kept before they are
+	// purged; 0 keeps them forever
+	TrashRetention Duration `json:"trashRetention"`
+	// PurgeInterval is how often the purge job runs
+	PurgeInterval Duration `json:"purgeInterval"`
+}
+
+// LogConfig configures structured logging
+type LogConfig struct {
+	// Level is one of debug, info, warn or error
+	Level string `json:"level"`
+	// Format is json or text
+	Format string `json:"format"`
+}
+
+// TracingConfig configures span export
+type TracingConfig struct {
+	// Exporter is none, stdout or memory
+	Exporter string `json:"exporter"`
+}
+
+// AuthConfig configures authentication of item and admin routes
+type AuthConfig struct {
+	Enabled bool `json:"enabled"`
+	// APIKeysFile persists hashed API keys created through the admin API
+	APIKeysFile string `json:"apiKeysFile"`
+	// BootstrapAPIKey is an admin key accepted in addition to stored keys
+	BootstrapAPIKey string    `json:"bootstrapApiKey"`
+	JWT             JWTConfig `json:"jwt"`
+	// ClientCerts maps client certificate common names to principals
+	ClientCerts map[string]CertPrincipal `json:"clientCerts"`
+}
+
+// CertPrincipal is the roles and tenant granted to a client certificate subject
+type CertPrincipal struct {
+	Roles  []string `json:"roles"`
+	Tenant string   `json:"tenant"`
+}
+
+// JWTConfig configures bearer token verification
+type JWTConfig struct {
+	HMACSecret       string   `json:"hmacSecret"`
+	RSAPublicKeyFile string   `json:"rsaPublicKeyFile"`
+	Issuer           string   `json:"issuer"`
+	Audience         string   `json:"audience"`
+	Leeway           Duration `json:"leeway"`
+}
+
+// AuthzConfig configures role-based authorization
+type AuthzConfig struct {
+	// Roles maps a role name to the permissions it grants; "*" grants all
+	Roles map[string][]string `json:"roles"`
+}
+
+// RateLimitConfig configures per-client token bucket rate limiting
+type RateLimitConfig struct {
+	Enabled bool `json:"enabled"`
+	// Key is what clients are told apart by: principal, tenant or ip
+	Key            string `json:"key"`
+	ReadPerMinute  int    `json:"readPerMinute"`
+	ReadBurst      int    `json:"readBurst"`
+	WritePerMinute int    `json:"writePerMinute"`
+	WriteBurst     int    `json:"writeBurst"`
+	// AuthFailuresPerMinute and AuthFailureBurst limit failed
+	// authentications per client IP; 0 disables that limit
+	AuthFailuresPerMinute int `json:"authFailuresPerMinute"`
+	AuthFailureBurst      int `json:"authFailureBurst"`
+}
+
+// AuditConfig configures the audit log
+type AuditConfig struct {
+	// File is the NDJSON file the audit log is appended to; empty keeps it in memory
+	File string `json:"file"`
+}
+
+// defaultConfig returns the configuration used when nothing is overridden
+func defaultConfig() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:                  ":8080",
+			ShutdownTimeout:       Duration{15 * time.Second},
+			ReadHeaderTimeout:     Duration{5 * time.Second},
+			ReadTimeout:           Duration{15 * time.Second},
+			WriteTimeout:          Duration{30 * time.Second},
+			IdleTimeout:           Duration{60 * time.Second},
+			MaxBodyBytes:          1 << 20,
+			MaxConcurrentRequests: 512,
+			TLS: TLSConfig{
+				ClientAuth:     ClientAuthNone,
+				ReloadInterval: Duration{30 * time.Second},
+			},
+		},
+		Broker: BrokerConfig{
+			URL:                  events.DefaultURL,
+			Queue:                events.DefaultQueue,
+			Heartbeat:            Duration{events.DefaultHeartbeat},
+			Locale:               events.DefaultLocale,
+			ConnectionName:       "go-server-crud",
+			UnknownSchemaVersion: events.UnknownVersionReject,
+		},
+		Store: StoreConfig{
+			LockTimeout:        Duration{100 * time.Millisecond},
+			MinSequenceTimeout: Duration{5 * time.Second},
+			TrashRetention:     Duration{30 * 24 * time.Hour},
+			PurgeInterval:      Duration{time.Hour},
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter: "none",
+		},
+		Auth: AuthConfig{
+			JWT: JWTConfig{
+				Leeway: Duration{30 * time.Second},
+			},
+		},
+		Authz: AuthzConfig{
+			Roles: defaultRoles(),
+		},
+		RateLimit: RateLimitConfig{
+			Key:            RateLimitByPrincipal,
+			ReadPerMinute:  600,
+			ReadBurst:      100,
+			WritePerMinute: 120,
+			WriteBurst:     20,
+
+			AuthFailuresPerMinute: 10,
+			AuthFailureBurst:      10,
+		},
+	}
+}
+
+// configField binds one setting to its flag and environment variable
+type configField struct {
+	flag  string
+	env   string
+	usage string
+	field func(c *Config) any
+}
+
+// configFields lists every setting that can be overridden from env or flags
+var configFields = []configField{
+	{"addr", "HTTP_ADDR", "HTTP listen address", func(c *Config) any { return &c.Server.Addr }},
+	{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "time allowed to drain in-flight work on shutdown", func(c *Config) any { return &c.Server.ShutdownTimeout }},
+	{"read-header-timeout", "HTTP_READ_HEADER_TIMEOUT", "time allowed to read request headers", func(c *Config) any { return &c.Server.ReadHeaderTimeout }},
+	{"read-timeout", "HTTP_READ_TIMEOUT", "time allowed to read a whole request", func(c *Config) any { return &c.Server.ReadTimeout }},
+	{"write-timeout", "HTTP_WRITE_TIMEOUT", "time allowed to write a response", func(c *Config) any { return &c.Server.WriteTimeout }},
+	{"idle-timeout", "HTTP_IDLE_TIMEOUT", "how long idle keep-alive connections are kept open", func(c *Config) any { return &c.Server.IdleTimeout }},
+	{"max-body-bytes", "HTTP_MAX_BODY_BYTES", "maximum request body size in bytes (0 for unlimited)", func(c *Config) any { return &c.Server.MaxBodyBytes }},
+	{"max-concurrent-requests", "HTTP_MAX_CONCURRENT_REQUESTS", "requests served at once before shedding load (0 for unlimited)", func(c *Config) any { return &c.Server.MaxConcurrentRequests }},
+	{"tls-cert-file", "TLS_CERT_FILE", "PEM certificate to serve HTTPS with", func(c *Config) any { return &c.Server.TLS.CertFile }},
+	{"tls-key-file", "TLS_KEY_FILE", "PEM private key for the TLS certificate", func(c *Config) any { return &c.Server.TLS.KeyFile }},
+	{"tls-client-ca-file", "TLS_CLIENT_CA_FILE", "CA bundle client certificates are verified against", func(c *Config) any { return &c.Server.TLS.ClientCAFile }},
+	{"tls-client-auth", "TLS_CLIENT_AUTH", "client certificate policy (none, optional, require)", func(c *Config) any { return &c.Server.TLS.ClientAuth }},
+	{"tls-reload-interval", "TLS_RELOAD_INTERVAL", "how often certificate files are checked for changes", func(c *Config) any { return &c.Server.TLS.ReloadInterval }},
+	{"amqp-url", "RABBITMQ_URL", "RabbitMQ connection URL", func(c *Config) any { return &c.Broker.URL }},
+	{"amqp-exchange", "AMQP_EXCHANGE", "exchange to publish item events to (empty for the default exchange)", func(c *Config) any { return &c.Broker.Exchange }},
+	{"amqp-queue", "AMQP_QUEUE", "queue item events are delivered to", func(c *Config) any { return &c.Broker.Queue }},
+	{"amqp-dead-letter-exchange", "AMQP_DEAD_LETTER_EXCHANGE", "exchange rejected item events are dead-lettered to (empty discards them)", func(c *Config) any { return &c.Broker.DeadLetterExchange }},
+	{"amqp-dead-letter-queue", "AMQP_DEAD_LETTER_QUEUE", "queue collecting dead-lettered item events (default <queue>.dead-letter)", func(c *Config) any { return &c.Broker.DeadLetterQueue }},
+	{"amqp-username", "AMQP_USERNAME", "broker username, overriding the URL's", func(c *Config) any { return &c.Broker.Username }},
+	{"amqp-password", "AMQP_PASSWORD", "broker password, overriding the URL's", func(c *Config) any { return &c.Broker.Password }},
+	{"amqp-password-file", "AMQP_PASSWORD_FILE", "file holding the broker password", func(c *Config) any { return &c.Broker.PasswordFile }},
+	{"amqp-tls-ca-file", "AMQP_TLS_CA_FILE", "CA bundle the broker certificate is verified against", func(c *Config) any { return &c.Broker.TLS.CAFile }},
+	{"amqp-tls-cert-file", "AMQP_TLS_CERT_FILE", "client certificate presented to the broker", func(c *Config) any { return &c.Broker.TLS.CertFile }},
+	{"amqp-tls-key-file", "AMQP_TLS_KEY_FILE", "private key for the broker client certificate", func(c *Config) any { return &c.Broker.TLS.KeyFile }},
+	{"amqp-tls-server-name", "AMQP_TLS_SERVER_NAME", "host name verified in the broker certificate", func(c *Config) any { return &c.Broker.TLS.ServerName }},
+	{"amqp-heartbeat", "AMQP_HEARTBEAT", "AMQP heartbeat interval", func(c *Config) any { return &c.Broker.Heartbeat }},
+	{"amqp-locale", "AMQP_LOCALE", "AMQP connection locale", func(c *Config) any { return &c.Broker.Locale }},
+	{"amqp-connection-name", "AMQP_CONNECTION_NAME", "connection name shown in the RabbitMQ management UI", func(c *Config) any { return &c.Broker.ConnectionName }},
+	{"amqp-unknown-schema-version", "AMQP_UNKNOWN_SCHEMA_VERSION", "consumer handling of events with a newer schema version (reject or pass)", func(c *Config) any { return &c.Broker.UnknownSchemaVersion }},
+	{"ready-require-broker", "READY_REQUIRE_BROKER", "fail readiness when the broker is unavailable", func(c *Config) any { return &c.Broker.Required }},
+	{"store-lock-timeout", "STORE_LOCK_TIMEOUT", "how long the readiness probe waits for the store lock", func(c *Config) any { return &c.Store.LockTimeout }},
+	{"store-max-items-per-tenant", "STORE_MAX_ITEMS_PER_TENANT", "per-tenant item quota (0 for unlimited)", func(c *Config) any { return &c.Store.MaxItemsPerTenant }},
+	{"min-sequence-timeout", "MIN_SEQUENCE_TIMEOUT", "how long reads wait for the change given as min-sequence", func(c *Config) any { return &c.Store.MinSequenceTimeout }},
+	{"store-trash-retention", "STORE_TRASH_RETENTION", "how long deleted items are kept before they are purged (0 keeps them)", func(c *Config) any { return &c.Store.TrashRetention }},
+	{"store-purge-interval", "STORE_PURGE_INTERVAL", "how often deleted items past their retention are purged", func(c *Config) any { return &c.Store.PurgeInterval }},
+	{"log-level", "LOG_LEVEL", "minimum log level (debug, info, warn, error)", func(c *Config) any { return &c.Log.Level }},
+	{"log-format", "LOG_FORMAT", "log output format (json, text)", func(c *Config) any { return &c.Log.Format }},
+	{"trace-exporter", "TRACE_EXPORTER", "span exporter (none, stdout, memory)", func(c *Config) any { return &c.Tracing.Exporter }},
+	{"auth-enabled", "AUTH_ENABLED", "require credentials on item and admin routes", func(c *Config) any { return &c.Auth.Enabled }},
+	{"auth-api-keys-file", "AUTH_API_KEYS_FILE", "file persisting hashed API keys", func(c *Config) any { return &c.Auth.APIKeysFile }},
+	{"auth-bootstrap-api-key", "AUTH_BOOTSTRAP_API_KEY", "admin API key accepted in addition to stored keys", func(c *Config) any { return &c.Auth.BootstrapAPIKey }},
+	{"jwt-hmac-secret", "JWT_HMAC_SECRET", "shared secret for HS256/384/512 tokens", func(c *Config) any { return &c.Auth.JWT.HMACSecret }},
+	{"jwt-rsa-public-key-file", "JWT_RSA_PUBLIC_KEY_FILE", "PEM public key for RS256/384/512 tokens", func(c *Config) any { return &c.Auth.JWT.RSAPublicKeyFile }},
+	{"jwt-issuer", "JWT_ISSUER", "required token issuer", func(c *Config) any { return &c.Auth.JWT.Issuer }},
+	{"jwt-audience", "JWT_AUDIENCE", "required token audience", func(c *Config) any { return &c.Auth.JWT.Audience }},
+	{"jwt-leeway", "JWT_LEEWAY", "clock skew allowed for exp and nbf", func(c *Config) any { return &c.Auth.JWT.Leeway }},
+	{"rate-limit-enabled", "RATE_LIMIT_ENABLED", "rate limit item routes per client", func(c *Config) any { return &c.RateLimit.Enabled }},
+	{"rate-limit-key", "RATE_LIMIT_KEY", "what clients are rate limited by (principal, tenant, ip)", func(c *Config) any { return &c.RateLimit.Key }},
+	{"rate-limit-read-per-minute", "RATE_LIMIT_READ_PER_MINUTE", "sustained read requests allowed per client per minute", func(c *Config) any { return &c.RateLimit.ReadPerMinute }},
+	{"rate-limit-read-burst", "RATE_LIMIT_READ_BURST", "read requests a client may burst", func(c *Config) any { return &c.RateLimit.ReadBurst }},
+	{"rate-limit-write-per-minute", "RATE_LIMIT_WRITE_PER_MINUTE", "sustained write requests allowed per client per minute", func(c *Config) any { return &c.RateLimit.WritePerMinute }},
+	{"rate-limit-write-burst", "RATE_LIMIT_WRITE_BURST", "write requests a client may burst", func(c *Config) any { return &c.RateLimit.WriteBurst }},
+	{"rate-limit-auth-failures-per-minute", "RATE_LIMIT_AUTH_FAILURES_PER_MINUTE", "sustained failed authentications allowed per client IP per minute (0 for unlimited)", func(c *Config) any { return &c.RateLimit.AuthFailuresPerMinute }},
+	{"rate-limit-auth-failure-burst", "RATE_LIMIT_AUTH_FAILURE_BURST", "failed authentications a client IP may burst", func(c *Config) any { return &c.RateLimit.AuthFailureBurst }},
+	{"audit-log-file", "AUDIT_LOG_FILE", "file the hash-chained audit log is appended to", func(c *Config) any { return &c.Audit.File }},
+}
+
+// flagValue holds the raw value of a config flag until precedence is applied
+type flagValue struct {
+	raw    string
+	isBool bool
+}
+
+func (v *flagValue) String() string     { return v.raw }
+func (v *flagValue) Set(s string) error { v.raw = s; return nil }
+func (v *flagValue) IsBoolFlag() bool   { return v.isBool }
+
+// setConfigValue parses raw into the field pointed to by ptr
+func setConfigValue(ptr any, raw string) error {
+	switch p := ptr.(type) {
+	case *string:
+		*p = raw
+	case *bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *int:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *Duration:
+		v, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		p.Duration = v
+	default:
+		return fmt.Errorf("unsupported config field type %T", ptr)
+	}
+	return nil
+}
+
+// loadConfig builds the configuration with precedence
+// defaults < config file < environment < command-line flags.
+// printConfig reports whether --print-config was given.
+func loadConfig(args []string, getenv func(string) string) (cfg Config, printConfig bool, err error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configPath := fs.String("config", getenv("CONFIG_FILE"), "path to a JSON config file")
+	fs.BoolVar(&printConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	flagValues := make(map[string]*flagValue, len(configFields))
+	probe := defaultConfig()
+	for _, f := range configFields {
+		_, isBool := f.field(&probe).(*bool)
+		flagValues[f.flag] = &flagValue{isBool: isBool}
+		fs.Var(flagValues[f.flag], f.flag, f.usage+" (env "+f.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, false, err
+	}
+
+	cfg = defaultConfig()
+	if *configPath != "" {
+		if err := loadConfigFile(*configPath, &cfg); err != nil {
+			return cfg, false, err
+		}
+	}
+	for _, f := range configFields {
+		if raw := getenv(f.env); raw != "" {
+			if err := setConfigValue(f.field(&cfg), raw); err != nil {
+				return cfg, false, fmt.Errorf("invalid %s: %w", f.env, err)
+			}
+		}
+	}
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range configFields {
+			if f.flag == fl.Name && flagErr == nil {
+				if err := setConfigValue(f.field(&cfg), flagValues[f.flag].raw); err != nil {
+					flagErr = fmt.Errorf("invalid -%s: %w", f.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, false, flagErr
+	}
+	return cfg, printConfig, cfg.Validate()
+}
+
+// loadConfigFile overlays the JSON file at path onto cfg
+func loadConfigFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting
+func (c Config) Validate() error {
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("server.addr: %w", err))
+	}
+	if c.Server.ShutdownTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("server.shutdownTimeout must be positive"))
+	}
+	if c.Server.ReadHeaderTimeout.Duration <= 0 || c.Server.ReadTimeout.Duration <= 0 ||
+		c.Server.WriteTimeout.Duration <= 0 || c.Server.IdleTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("server timeouts must be positive"))
+	}
+	if c.Server.MaxBodyBytes < 0 || c.Server.MaxConcurrentRequests < 0 {
+		errs = append(errs, errors.New("server.maxBodyBytes and server.maxConcurrentRequests must not be negative"))
+	}
+	errs = append(errs, c.Server.TLS.validate()...)
+	if u, err := url.Parse(c.Broker.URL); err != nil {
+		errs = append(errs, errors.New("broker.url is not a valid URL"))
+	} else if u.Scheme != "amqp" && u.Scheme != "amqps" {
+		errs = append(errs, errors.New("broker.url must use the amqp or amqps scheme"))
+	}
+	if c.Broker.Queue == "" {
+		errs = append(errs, errors.New("broker.queue must not be empty"))
+	}
+	if c.Broker.Password != "" && c.Broker.PasswordFile != "" {
+		errs = append(errs, errors.New("broker: set password or passwordFile, not both"))
+	}
+	if (c.Broker.Password != "" || c.Broker.PasswordFile != "") && c.Broker.Username == "" {
+		errs = append(errs, errors.New("broker: a password requires a username"))
+	}
+	if (c.Broker.TLS.CertFile == "") != (c.Broker.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("broker.tls: certFile and keyFile must be set together"))
+	}
+	if c.Broker.Heartbeat.Duration < 0 {
+		errs = append(errs, errors.New("broker.heartbeat must not be negative"))
+	}
+	if c.Store.LockTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("store.lockTimeout must be positive"))
+	}
+	if c.Store.MinSequenceTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("store.minSequenceTimeout must be positive"))
+	}
+	if c.Store.TrashRetention.Duration < 0 {
+		errs = append(errs, errors.New("store.trashRetention must not be negative"))
+	}
+	if c.Store.PurgeInterval.Duration <= 0 {
+		errs = append(errs, errors.New("store.purgeInterval must be positive"))
+	}
+	if c.Store.MaxItemsPerTenant < 0 {
+		errs = append(errs, errors.New("store.maxItemsPerTenant must not be negative"))
+	}
+	if _, err := newLogger(io.Discard, c.Log); err != nil {
+		errs = append(errs, fmt.Errorf("log: %w", err))
+	}
+	if _, err := newSpanExporter(io.Discard, c.Tracing); err != nil {
+		errs = append(errs, fmt.Errorf("tracing: %w", err))
+	}
+	if c.Auth.Enabled && c.Auth.APIKeysFile == "" && c.Auth.BootstrapAPIKey == "" &&
+		c.Auth.JWT.HMACSecret == "" && c.Auth.JWT.RSAPublicKeyFile == "" && c.Server.TLS.ClientCAFile == "" {
+		errs = append(errs, errors.New("auth: enabled but no API keys file, bootstrap key, JWT key or client CA is configured"))
+	}
+	if _, err := NewPolicy(c.Authz); err != nil {
+		errs = append(errs, fmt.Errorf("authz: %w", err))
+	}
+	if c.Auth.JWT.Leeway.Duration < 0 {
+		errs = append(errs, errors.New("auth.jwt.leeway must not be negative"))
+	}
+	for name, cp := range c.Auth.ClientCerts {
+		if cp.Tenant != "" && !tenantIDPattern.MatchString(cp.Tenant) {
+			errs = append(errs, fmt.Errorf("auth.clientCerts[%q]: invalid tenant ID %q", name, cp.Tenant))
+		}
+	}
+	switch c.Broker.UnknownSchemaVersion {
+	case events.UnknownVersionReject, events.UnknownVersionPass:
+	default:
+		errs = append(errs, fmt.Errorf("broker.unknownSchemaVersion must be reject or pass; got %q", c.Broker.UnknownSchemaVersion))
+	}
+	switch c.RateLimit.Key {
+	case RateLimitByPrincipal, RateLimitByTenant, RateLimitByIP:
+	default:
+		errs = append(errs, fmt.Errorf("rateLimit.key must be principal, tenant or ip; got %q", c.RateLimit.Key))
+	}
+	if c.RateLimit.Enabled && (c.RateLimit.ReadPerMinute <= 0 || c.RateLimit.ReadBurst <= 0 ||
+		c.RateLimit.WritePerMinute <= 0 || c.RateLimit.WriteBurst <= 0) {
+		errs = append(errs, errors.New("rateLimit: rates and bursts must be positive"))
+	}
+	if c.RateLimit.AuthFailuresPerMinute < 0 || c.RateLimit.AuthFailureBurst < 0 ||
+		(c.RateLimit.AuthFailuresPerMinute > 0) != (c.RateLimit.AuthFailureBurst > 0) {
+		errs = append(errs, errors.New("rateLimit: authFailuresPerMinute and authFailureBurst must both be positive, or both 0"))
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks the TLS settings are complete and consistent
+func (c TLSConfig) validate() []error {
+	var errs []error
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls: certFile and keyFile must be set together"))
+	}
+	switch c.ClientAuth {
+	case ClientAuthNone:
+	case ClientAuthOptional, ClientAuthRequire:
+		if c.CertFile == "" || c.ClientCAFile == "" {
+			errs = append(errs, fmt.Errorf("server.tls: clientAuth %q requires a certificate and clientCaFile", c.ClientAuth))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("server.tls.clientAuth must be none, optional or require; got %q", c.ClientAuth))
+	}
+	if c.ReloadInterval.Duration <= 0 {
+		errs = append(errs, errors.New("server.tls.reloadInterval must be positive"))
+	}
+	return errs
+}
+
+// Redacted returns a copy of the configuration that is safe to print or log
+func (c Config) Redacted() Config {
+	c.Broker.URL = redactURL(c.Broker.URL)
+	c.Broker.Password = redactSecret(c.Broker.Password)
+	c.Auth.BootstrapAPIKey = redactSecret(c.Auth.BootstrapAPIKey)
+	c.Auth.JWT.HMACSecret = redactSecret(c.Auth.JWT.HMACSecret)
+	return c
+}
+
+// redactSecret hides a non-empty secret value
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "REDACTED"
+}
+
+// redactURL hides the password in a URL's user info
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "REDACTED"
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	return u.String()
+}
+
+// printRedactedConfig writes the configuration as indented JSON with secrets hidden
+func printRedactedConfig(w io.Writer, cfg Config) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cfg.Redacted())
+}
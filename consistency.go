@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SequenceHeader carries the change sequence a response reflects. Write
+// responses return the sequence of their change, which clients pass back as
+// min-sequence to read their own writes.
+const SequenceHeader = "X-Sequence"
+
+// MinSequenceParam is the query parameter that makes a read wait until the
+// given change sequence has been applied
+const MinSequenceParam = "min-sequence"
+
+// minSequenceTimeout bounds how long a read waits for its min-sequence
+var minSequenceTimeout = 5 * time.Second
+
+// WaitForSequence blocks until change n of tenant has been applied or ctx
+// ends
+func (s *ItemStore) WaitForSequence(ctx context.Context, tenant string, n int64) error {
+	for {
+		s.mu.Lock()
+		applied, changed := s.sequenceLocked(tenant), s.changed
+		s.mu.Unlock()
+		if applied >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("sequence %d not applied, at %d: %w", n, applied, ctx.Err())
+		}
+	}
+}
+
+// setSequenceHeader reports the sequence of the tenant's latest change.
+// Callers hold store.mu.
+func setSequenceHeader(w http.ResponseWriter, tenant string) {
+	w.Header().Set(SequenceHeader, strconv.FormatInt(store.sequenceLocked(tenant), 10))
+}
+
+// withMinSequence makes a read with min-sequence wait, for at most
+// minSequenceTimeout, until that change of the request's tenant has been
+// applied, and answers 503 when it has not
+func withMinSequence(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		param := r.URL.Query().Get(MinSequenceParam)
+		if param == "" {
+			next(w, r)
+			return
+		}
+		n, err := strconv.ParseInt(param, 10, 64)
+		if err != nil || n < 0 {
+			writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("%s must be a non-negative integer", MinSequenceParam))
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), minSequenceTimeout)
+		defer cancel()
+		if err := store.WaitForSequence(ctx, tenantFromContext(r.Context()), n); err != nil {
+			w.Header().Set("Retry-After", "1")
+			writeProblem(w, r, http.StatusServiceUnavailable, fmt.Sprintf("change %d has not been applied within %s", n, minSequenceTimeout))
+			return
+		}
+		next(w, r)
+	}
+}
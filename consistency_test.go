@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useMinSequenceTimeout sets how long reads wait for min-sequence
+func useMinSequenceTimeout(t *testing.T, d time.Duration) {
+	t.Helper()
+	previous := minSequenceTimeout
+	minSequenceTimeout = d
+	t.Cleanup(func() { minSequenceTimeout = previous })
+}
+
+func TestWaitForSequence(t *testing.T) {
+	t.Run("AlreadyApplied", func(t *testing.T) {
+		useStore(t, 0, Item{ID: 1, Name: "Anvil"})
+		if err := store.WaitForSequence(context.Background(), DefaultTenant, 1); err != nil {
+			t.Errorf("Expected no wait; got %v", err)
+		}
+	})
+
+	t.Run("AppliedWhileWaiting", func(t *testing.T) {
+		// Arrange
+		useStore(t, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			store.mu.Lock()
+			store.addLocked(DefaultTenant, Item{Name: "a"})
+			store.addLocked(DefaultTenant, Item{Name: "b"})
+			store.mu.Unlock()
+		}()
+
+		// Act
+		err := store.WaitForSequence(ctx, DefaultTenant, 2)
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected the wait to end once change 2 is applied; got %v", err)
+		}
+	})
+
+	t.Run("TimesOut", func(t *testing.T) {
+		useStore(t, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := store.WaitForSequence(ctx, DefaultTenant, 1); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected deadline exceeded; got %v", err)
+		}
+	})
+
+	t.Run("OtherTenantsDoNotCount", func(t *testing.T) {
+		// Arrange
+		useStore(t, 0, Item{ID: 1, Name: "Anvil"})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		// Act
+		err := store.WaitForSequence(ctx, "acme", 1)
+
+		// Assert
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected deadline exceeded; got %v", err)
+		}
+		if _, ok := store.tenants["acme"]; ok {
+			t.Error("Expected waiting not to create the tenant")
+		}
+	})
+}
+
+func TestWithMinSequence(t *testing.T) {
+	useMinSequenceTimeout(t, 10*time.Millisecond)
+	useStore(t, 0, Item{ID: 1, Name: "Anvil"}, Item{ID: 2, Name: "Rocket"})
+	handler := withMinSequence(getItems)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{"NoParameter", "", http.StatusOK},
+		{"Applied", "?min-sequence=2", http.StatusOK},
+		{"NotApplied", "?min-sequence=3", http.StatusServiceUnavailable},
+		{"Invalid", "?min-sequence=latest", http.StatusBadRequest},
+		{"Negative", "?min-sequence=-1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			req := httptest.NewRequest(http.MethodGet, "/items"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			// Act
+			handler(rec, req)
+
+			// Assert
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d; got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Header().Get(SequenceHeader) != "2" {
+				t.Errorf("Expected %s 2; got %q", SequenceHeader, rec.Header().Get(SequenceHeader))
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+				t.Error("Expected a Retry-After header")
+			}
+		})
+	}
+}
+
+func TestWriteReturnsSequence(t *testing.T) {
+	// Arrange
+	useStore(t, 0, Item{ID: 1, Name: "Anvil"})
+	req := httptest.NewRequest(http.MethodPost, "/items/add", strings.NewReader(`{"name":"Rocket"}`))
+	rec := httptest.NewRecorder()
+
+	// Act
+	addItem(rec, req)
+
+	// Assert
+	if got := rec.Header().Get(SequenceHeader); got != "2" {
+		t.Errorf("Expected %s 2; got %q", SequenceHeader, got)
+	}
+}
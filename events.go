@@ -1,210 +1,128 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
+	"strconv"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
-)
-
-// EventType represents the type of event
-type EventType string
 
-const (
-	EventItemCreated EventType = "item.created"
-	EventItemUpdated EventType = "item.updated"
-	EventItemDeleted EventType = "item.deleted"
+	"github.com/us-service/Go-server-crud/pkg/events"
 )
 
-// ItemEvent represents an event related to an item
-type ItemEvent struct {
-	Type      EventType `json:"type"`
-	Item      Item      `json:"item"`
-	Timestamp time.Time `json:"timestamp"`
+// eventInstrumentation traces, correlates and counts published and consumed
+// events
+var eventInstrumentation = events.Instrumentation{
+	StartPublish:  startEventPublish,
+	StartDelivery: startEventDelivery,
 }
 
-// EventPublisher handles publishing events to RabbitMQ
-type EventPublisher struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	queue   amqp.Queue
+// eventTopology is the exchange and queue configured for item events
+func eventTopology(cfg BrokerConfig) events.Topology {
+	return events.Topology{
+		Exchange:           cfg.Exchange,
+		Queue:              cfg.Queue,
+		DeadLetterExchange: cfg.DeadLetterExchange,
+		DeadLetterQueue:    cfg.DeadLetterQueue,
+	}
 }
 
-// NewEventPublisher creates a new event publisher
-func NewEventPublisher(amqpURL string) (*EventPublisher, error) {
-	conn, err := amqp.Dial(amqpURL)
+// NewEventPublisher connects to the configured broker and declares the
+// event topology
+func NewEventPublisher(cfg BrokerConfig) (*events.Publisher, error) {
+	conn, err := dialBroker(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
-
-	ch, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
-	}
-
-	q, err := ch.QueueDeclare(
-		"item_events", // name
-		true,          // durable
-		false,         // delete when unused
-		false,         // exclusive
-		false,         // no-wait
-		nil,           // arguments
-	)
-	if err != nil {
-		ch.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare queue: %w", err)
-	}
-
-	return &EventPublisher{
-		conn:    conn,
-		channel: ch,
-		queue:   q,
-	}, nil
+	return events.NewPublisher(conn, eventTopology(cfg), eventInstrumentation)
 }
 
-// Publish publishes an event to RabbitMQ
-func (ep *EventPublisher) Publish(event ItemEvent) error {
-	if ep.channel == nil {
-		return fmt.Errorf("channel is not initialized")
-	}
-
-	body, err := json.Marshal(event)
-	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
-	}
-
-	err = ep.channel.Publish(
-		"",           // exchange
-		ep.queue.Name, // routing key
-		false,        // mandatory
-		false,        // immediate
-		amqp.Publishing{
-			DeliveryMode: amqp.Persistent,
-			ContentType:  "application/json",
-			Body:         body,
-		},
-	)
+// NewEventConsumer connects to the configured broker and consumes its
+// event queue
+func NewEventConsumer(cfg BrokerConfig) (*events.Consumer, error) {
+	conn, err := dialBroker(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to publish event: %w", err)
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
-
-	log.Printf("Published event: %s for item ID: %d", event.Type, event.Item.ID)
-	return nil
+	return events.NewConsumer(conn, eventTopology(cfg), events.ConsumerOptions{
+		UnknownVersion:  cfg.UnknownSchemaVersion,
+		Instrumentation: eventInstrumentation,
+	})
 }
 
-// Close closes the connection and channel
-func (ep *EventPublisher) Close() error {
-	if ep.channel != nil {
-		if err := ep.channel.Close(); err != nil {
-			return err
-		}
-	}
-	if ep.conn != nil {
-		if err := ep.conn.Close(); err != nil {
-			return err
-		}
+// itemEvent builds the event published for a change to item, numbered with
+// the item's current version and the tenant's latest change sequence.
+// Callers hold store.mu.
+func itemEvent(ctx context.Context, eventType events.EventType, tenant string, item Item) events.ItemEvent {
+	return events.ItemEvent{
+		Type:        eventType,
+		TenantID:    tenant,
+		Item:        events.Item(item),
+		ItemVersion: store.tenantLocked(tenant).versions[item.ID],
+		Sequence:    store.tenantLocked(tenant).sequence,
+		Epoch:       store.epoch,
+		Timestamp:   time.Now(),
+		Actor:       actorFromContext(ctx),
 	}
-	return nil
 }
 
-// EventConsumer handles consuming events from RabbitMQ
-type EventConsumer struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	queue   amqp.Queue
-}
-
-// NewEventConsumer creates a new event consumer
-func NewEventConsumer(amqpURL string) (*EventConsumer, error) {
-	conn, err := amqp.Dial(amqpURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
-	}
+// startEventPublish records a publish span and the publish metrics, and
+// sends the trace context and the request ID found in ctx as headers
+func startEventPublish(ctx context.Context, event events.ItemEvent, exchange, routingKey string) (context.Context, amqp.Table, func(error)) {
+	ctx, span := startSpan(ctx, "publish "+string(event.Type))
+	span.SetAttribute("messaging.destination", exchange)
+	span.SetAttribute("messaging.routing_key", routingKey)
 
-	ch, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
+	headers := amqp.Table{events.TraceparentHeader: span.SpanContext().Traceparent()}
+	if requestID := requestIDFromContext(ctx); requestID != "" {
+		headers[events.RequestIDHeader] = requestID
 	}
 
-	q, err := ch.QueueDeclare(
-		"item_events", // name
-		true,          // durable
-		false,         // delete when unused
-		false,         // exclusive
-		false,         // no-wait
-		nil,           // arguments
-	)
-	if err != nil {
-		ch.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare queue: %w", err)
+	start := time.Now()
+	return ctx, headers, func(err error) {
+		eventPublishDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			eventsPublishedTotal.Inc("failure")
+			span.RecordError(err)
+		} else {
+			eventsPublishedTotal.Inc("success")
+		}
+		span.End()
 	}
-
-	return &EventConsumer{
-		conn:    conn,
-		channel: ch,
-		queue:   q,
-	}, nil
 }
 
-// Consume starts consuming events from RabbitMQ
-func (ec *EventConsumer) Consume(handler func(ItemEvent) error) error {
-	if ec.channel == nil {
-		return fmt.Errorf("channel is not initialized")
-	}
-
-	msgs, err := ec.channel.Consume(
-		ec.queue.Name, // queue
-		"",            // consumer
-		false,         // auto-ack
-		false,         // exclusive
-		false,         // no-local
-		false,         // no-wait
-		nil,           // args
-	)
-	if err != nil {
-		return fmt.Errorf("failed to register consumer: %w", err)
-	}
-
-	go func() {
-		for d := range msgs {
-			var event ItemEvent
-			if err := json.Unmarshal(d.Body, &event); err != nil {
-				log.Printf("Error unmarshaling event: %v", err)
-				d.Nack(false, false) // reject message
-				continue
+// startEventDelivery restores the request ID and remote trace context of a
+// delivery and records a process span and the consume metrics
+func startEventDelivery(d amqp.Delivery) (context.Context, func(events.DeliveryResult)) {
+	ctx := deliveryContext(d)
+	ctx, span := startSpan(ctx, "process "+d.RoutingKey)
+	return ctx, func(r events.DeliveryResult) {
+		if r.Event != nil {
+			span.SetAttribute("event.type", r.Event.Type)
+			span.SetAttribute("item.id", r.Event.Item.ID)
+			if r.SchemaVersion < events.EventSchemaVersion {
+				eventsUpcastTotal.Inc(strconv.Itoa(r.SchemaVersion))
 			}
-
-			if err := handler(event); err != nil {
-				log.Printf("Error handling event: %v", err)
-				d.Nack(false, true) // requeue message
-			} else {
-				d.Ack(false) // acknowledge message
-				log.Printf("Processed event: %s for item ID: %d", event.Type, event.Item.ID)
+			if r.Panicked {
+				eventHandlerPanicsTotal.Inc(string(r.Event.Type))
 			}
 		}
-	}()
-
-	log.Printf("Consumer started, waiting for events...")
-	return nil
-}
-
-// Close closes the connection and channel
-func (ec *EventConsumer) Close() error {
-	if ec.channel != nil {
-		if err := ec.channel.Close(); err != nil {
-			return err
+		if r.Err != nil {
+			span.RecordError(r.Err)
 		}
+		eventsConsumedTotal.Inc(r.Outcome)
+		span.End()
 	}
-	if ec.conn != nil {
-		if err := ec.conn.Close(); err != nil {
-			return err
+}
+
+// deliveryContext restores the request ID and remote trace context of a delivery
+func deliveryContext(d amqp.Delivery) context.Context {
+	ctx := withRequestIDContext(context.Background(), events.DeliveryRequestID(d))
+	if tp, ok := d.Headers[events.TraceparentHeader].(string); ok {
+		if sc, ok := parseTraceparent(tp); ok {
+			ctx = contextWithRemoteSpanContext(ctx, sc)
 		}
 	}
-	return nil
+	return ctx
 }
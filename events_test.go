@@ -1,269 +1,117 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"slices"
 	"testing"
-	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
-)
-
-// MockAMQPChannel is a mock implementation of AMQP channel for testing
-type MockAMQPChannel struct {
-	publishedMessages []amqp.Publishing
-	queueName         string
-	consumeHandler    func(amqp.Delivery)
-	shouldFail        bool
-}
-
-func (m *MockAMQPChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
-	if m.shouldFail {
-		return amqp.ErrClosed
-	}
-	m.publishedMessages = append(m.publishedMessages, msg)
-	return nil
-}
-
-func (m *MockAMQPChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
-	if m.shouldFail {
-		return amqp.Queue{}, amqp.ErrClosed
-	}
-	m.queueName = name
-	return amqp.Queue{Name: name}, nil
-}
-
-func (m *MockAMQPChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
-	if m.shouldFail {
-		return nil, amqp.ErrClosed
-	}
-	deliveryChan := make(chan amqp.Delivery, 10)
-	return deliveryChan, nil
-}
-
-func (m *MockAMQPChannel) Close() error {
-	return nil
-}
-
-// TestEventPublisher tests the event publisher
-func TestEventPublisher(t *testing.T) {
-	// This test validates the event structure
-	t.Run("ValidateEventStructure", func(t *testing.T) {
-		event := ItemEvent{
-			Type:      EventItemCreated,
-			Item:      Item{ID: 1, Name: "Test Item"},
-			Timestamp: time.Now(),
-		}
-
-		// Validate event can be marshaled
-		data, err := json.Marshal(event)
-		if err != nil {
-			t.Fatalf("Failed to marshal event: %v", err)
-		}
-
-		// Validate event can be unmarshaled
-		var unmarshaled ItemEvent
-		if err := json.Unmarshal(data, &unmarshaled); err != nil {
-			t.Fatalf("Failed to unmarshal event: %v", err)
-		}
 
-		if unmarshaled.Type != EventItemCreated {
-			t.Errorf("Expected event type %s, got %s", EventItemCreated, unmarshaled.Type)
-		}
-		if unmarshaled.Item.ID != 1 || unmarshaled.Item.Name != "Test Item" {
-			t.Errorf("Item data mismatch: %+v", unmarshaled.Item)
-		}
-	})
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
 
-	t.Run("EventTypesAreDefined", func(t *testing.T) {
-		if EventItemCreated == "" {
-			t.Error("EventItemCreated is not defined")
-		}
-		if EventItemUpdated == "" {
-			t.Error("EventItemUpdated is not defined")
-		}
-		if EventItemDeleted == "" {
-			t.Error("EventItemDeleted is not defined")
-		}
+// TestItemJSONMatchesEventsPackage fails when the server's Item and the
+// Item carried by events encode differently
+func TestItemJSONMatchesEventsPackage(t *testing.T) {
+	// Arrange
+	item := Item{ID: 7, Name: "Anvil"}
 
-		// Validate they are different
-		if EventItemCreated == EventItemUpdated || EventItemCreated == EventItemDeleted || EventItemUpdated == EventItemDeleted {
-			t.Error("Event types should be unique")
+	t.Run("Fields", func(t *testing.T) {
+		if got, want := jsonFields(events.Item{}), jsonFields(Item{}); !slices.Equal(got, want) {
+			t.Errorf("Expected events.Item fields %v; got %v", want, got)
 		}
 	})
-}
 
-// TestEventPublisherPublish tests the Publish method
-func TestEventPublisherPublish(t *testing.T) {
-	t.Run("PublishWithNilChannel", func(t *testing.T) {
-		publisher := &EventPublisher{
-			channel: nil,
-		}
+	t.Run("RoundTrip", func(t *testing.T) {
+		// Act
+		server, _ := json.Marshal(item)
+		event, _ := json.Marshal(events.Item(item))
+		var decoded events.Item
+		dec := json.NewDecoder(bytes.NewReader(server))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&decoded)
 
-		event := ItemEvent{
-			Type:      EventItemCreated,
-			Item:      Item{ID: 1, Name: "Test"},
-			Timestamp: time.Now(),
+		// Assert
+		if !bytes.Equal(server, event) {
+			t.Errorf("Expected identical JSON; server %s, events %s", server, event)
 		}
-
-		err := publisher.Publish(event)
-		if err == nil {
-			t.Error("Expected error when publishing with nil channel")
+		if err != nil || decoded != events.Item(item) {
+			t.Errorf("Expected the server's JSON to decode into events.Item; got %+v (%v)", decoded, err)
 		}
 	})
 }
 
-// TestEventConsumer tests the event consumer
-func TestEventConsumer(t *testing.T) {
-	t.Run("ConsumeWithNilChannel", func(t *testing.T) {
-		consumer := &EventConsumer{
-			channel: nil,
-		}
+func TestItemEventVersion(t *testing.T) {
+	// Arrange
+	useStore(t, 0, Item{ID: 1, Name: "Anvil"})
+	store.touchLocked(DefaultTenant, 1)
 
-		err := consumer.Consume(func(event ItemEvent) error {
-			return nil
-		})
+	// Act
+	event := itemEvent(context.Background(), events.EventItemUpdated, DefaultTenant, Item{ID: 1, Name: "Anvil"})
 
-		if err == nil {
-			t.Error("Expected error when consuming with nil channel")
-		}
-	})
+	// Assert
+	if event.ItemVersion != 2 {
+		t.Errorf("Expected item version 2; got %d", event.ItemVersion)
+	}
 }
 
-// TestItemEvent tests the ItemEvent structure
-func TestItemEvent(t *testing.T) {
-	t.Run("CreateItemEvent", func(t *testing.T) {
-		item := Item{ID: 1, Name: "Test Item"}
-		timestamp := time.Now()
-
-		event := ItemEvent{
-			Type:      EventItemCreated,
-			Item:      item,
-			Timestamp: timestamp,
-		}
-
-		if event.Type != EventItemCreated {
-			t.Errorf("Expected type %s, got %s", EventItemCreated, event.Type)
-		}
-		if event.Item.ID != 1 {
-			t.Errorf("Expected item ID 1, got %d", event.Item.ID)
-		}
-		if event.Item.Name != "Test Item" {
-			t.Errorf("Expected item name 'Test Item', got %s", event.Item.Name)
-		}
-	})
-
-	t.Run("UpdateItemEvent", func(t *testing.T) {
-		item := Item{ID: 2, Name: "Updated Item"}
-		event := ItemEvent{
-			Type:      EventItemUpdated,
-			Item:      item,
-			Timestamp: time.Now(),
-		}
-
-		if event.Type != EventItemUpdated {
-			t.Errorf("Expected type %s, got %s", EventItemUpdated, event.Type)
-		}
-		if event.Item.ID != 2 {
-			t.Errorf("Expected item ID 2, got %d", event.Item.ID)
-		}
-	})
+func TestStartEventPublish(t *testing.T) {
+	// Arrange
+	exporter := useMemoryExporter(t)
+	ctx := withRequestIDContext(context.Background(), "req-1")
+	event := itemEvent(ctx, events.EventItemCreated, "acme", Item{ID: 1, Name: "Anvil"})
+	before := eventsPublishedTotal.Value("failure")
 
-	t.Run("DeleteItemEvent", func(t *testing.T) {
-		item := Item{ID: 3, Name: "Deleted Item"}
-		event := ItemEvent{
-			Type:      EventItemDeleted,
-			Item:      item,
-			Timestamp: time.Now(),
-		}
+	// Act
+	_, headers, finish := startEventPublish(ctx, event, "items", "acme.item.created")
+	finish(errors.New("channel closed"))
 
-		if event.Type != EventItemDeleted {
-			t.Errorf("Expected type %s, got %s", EventItemDeleted, event.Type)
-		}
-		if event.Item.ID != 3 {
-			t.Errorf("Expected item ID 3, got %d", event.Item.ID)
-		}
-	})
+	// Assert
+	if headers[events.RequestIDHeader] != "req-1" || headers[events.TraceparentHeader] == nil {
+		t.Errorf("Expected request ID and traceparent headers; got %v", headers)
+	}
+	if got := eventsPublishedTotal.Value("failure"); got != before+1 {
+		t.Errorf("Expected the failure to be counted; got %v", got)
+	}
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].Attributes["messaging.routing_key"] != "acme.item.created" {
+		t.Errorf("Expected one publish span; got %+v", spans)
+	}
 }
 
-// TestEventSerialization tests JSON serialization of events
-func TestEventSerialization(t *testing.T) {
-	t.Run("SerializeAndDeserialize", func(t *testing.T) {
-		originalEvent := ItemEvent{
-			Type: EventItemCreated,
-			Item: Item{
-				ID:   123,
-				Name: "Serialization Test",
-			},
-			Timestamp: time.Now().UTC().Truncate(time.Second),
-		}
-
-		// Serialize
-		data, err := json.Marshal(originalEvent)
-		if err != nil {
-			t.Fatalf("Failed to serialize event: %v", err)
-		}
-
-		// Deserialize
-		var deserializedEvent ItemEvent
-		if err := json.Unmarshal(data, &deserializedEvent); err != nil {
-			t.Fatalf("Failed to deserialize event: %v", err)
-		}
-
-		// Verify
-		if deserializedEvent.Type != originalEvent.Type {
-			t.Errorf("Type mismatch: expected %s, got %s", originalEvent.Type, deserializedEvent.Type)
-		}
-		if deserializedEvent.Item.ID != originalEvent.Item.ID {
-			t.Errorf("Item ID mismatch: expected %d, got %d", originalEvent.Item.ID, deserializedEvent.Item.ID)
-		}
-		if deserializedEvent.Item.Name != originalEvent.Item.Name {
-			t.Errorf("Item name mismatch: expected %s, got %s", originalEvent.Item.Name, deserializedEvent.Item.Name)
-		}
-	})
-
-	t.Run("SerializeMultipleEvents", func(t *testing.T) {
-		events := []ItemEvent{
-			{Type: EventItemCreated, Item: Item{ID: 1, Name: "Event 1"}, Timestamp: time.Now()},
-			{Type: EventItemUpdated, Item: Item{ID: 2, Name: "Event 2"}, Timestamp: time.Now()},
-			{Type: EventItemDeleted, Item: Item{ID: 3, Name: "Event 3"}, Timestamp: time.Now()},
-		}
-
-		for i, event := range events {
-			data, err := json.Marshal(event)
-			if err != nil {
-				t.Errorf("Failed to serialize event %d: %v", i, err)
-			}
-
-			var unmarshaled ItemEvent
-			if err := json.Unmarshal(data, &unmarshaled); err != nil {
-				t.Errorf("Failed to deserialize event %d: %v", i, err)
-			}
-
-			if unmarshaled.Type != event.Type {
-				t.Errorf("Event %d type mismatch", i)
-			}
-		}
-	})
+func TestStartEventDeliveryCountsUpcasts(t *testing.T) {
+	before := eventsUpcastTotal.Value("1")
+	_, finish := startEventDelivery(amqp.Delivery{})
+	finish(events.DeliveryResult{Outcome: events.OutcomeProcessed, Event: &events.ItemEvent{}, SchemaVersion: 1})
+	if got := eventsUpcastTotal.Value("1"); got != before+1 {
+		t.Errorf("Expected the upcast to be counted; got %v", got)
+	}
 }
 
-// TestEventPublisherClose tests closing the publisher
-func TestEventPublisherClose(t *testing.T) {
-	t.Run("CloseWithNilChannelAndConnection", func(t *testing.T) {
-		publisher := &EventPublisher{}
-		err := publisher.Close()
-		if err != nil {
-			t.Errorf("Expected no error when closing nil publisher, got %v", err)
-		}
+func TestStartEventDeliveryCountsPanics(t *testing.T) {
+	before := eventHandlerPanicsTotal.Value("item.updated")
+	_, finish := startEventDelivery(amqp.Delivery{})
+	finish(events.DeliveryResult{
+		Outcome:       events.OutcomeRequeued,
+		Event:         &events.ItemEvent{Type: events.EventItemUpdated},
+		SchemaVersion: events.EventSchemaVersion,
+		Panicked:      true,
+		Err:           events.ErrHandlerPanic,
 	})
+	if got := eventHandlerPanicsTotal.Value("item.updated"); got != before+1 {
+		t.Errorf("Expected the panic to be counted; got %v", got)
+	}
 }
 
-// TestEventConsumerClose tests closing the consumer
-func TestEventConsumerClose(t *testing.T) {
-	t.Run("CloseWithNilChannelAndConnection", func(t *testing.T) {
-		consumer := &EventConsumer{}
-		err := consumer.Close()
-		if err != nil {
-			t.Errorf("Expected no error when closing nil consumer, got %v", err)
-		}
-	})
+func TestUnknownSchemaVersionConfig(t *testing.T) {
+	if _, _, err := loadConfig(nil, envMap(map[string]string{"AMQP_UNKNOWN_SCHEMA_VERSION": "ignore"})); err == nil {
+		t.Error("Expected configuration error")
+	}
+	cfg, _, err := loadConfig(nil, envMap(map[string]string{"AMQP_UNKNOWN_SCHEMA_VERSION": "pass"}))
+	if err != nil || cfg.Broker.UnknownSchemaVersion != events.UnknownVersionPass {
+		t.Errorf("Expected pass policy; got %q (%v)", cfg.Broker.UnknownSchemaVersion, err)
+	}
 }
@@ -2,6 +2,9 @@ module github.com/us-service/Go-server-crud/examples/consumer
 
 go 1.24.1
 
-require github.com/rabbitmq/amqp091-go v1.10.0
+require (
+	github.com/rabbitmq/amqp091-go v1.10.0
+	github.com/us-service/Go-server-crud v0.0.0-00010101000000-000000000000
+)
 
 replace github.com/us-service/Go-server-crud => ../..
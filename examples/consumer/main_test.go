@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+func TestBrokerFromEnv(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		// Act
+		connection, topology, err := brokerFromEnv()
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if connection.URL != events.DefaultURL || connection.Heartbeat != events.DefaultHeartbeat || connection.Locale != events.DefaultLocale {
+			t.Errorf("Expected the server's connection defaults; got %+v", connection)
+		}
+		if topology.Queue != events.DefaultQueue || topology.Exchange != "" {
+			t.Errorf("Expected the server's topology defaults; got %+v", topology)
+		}
+	})
+
+	t.Run("ServerVariables", func(t *testing.T) {
+		// Arrange
+		t.Setenv("RABBITMQ_URL", "amqps://rabbit.internal/")
+		t.Setenv("AMQP_USERNAME", "items")
+		t.Setenv("AMQP_PASSWORD_FILE", "/run/secrets/amqp")
+		t.Setenv("AMQP_TLS_CA_FILE", "ca.crt")
+		t.Setenv("AMQP_HEARTBEAT", "30s")
+		t.Setenv("AMQP_EXCHANGE", "items")
+		t.Setenv("AMQP_QUEUE", "acme_events")
+		t.Setenv("AMQP_DEAD_LETTER_EXCHANGE", "items.dlx")
+
+		// Act
+		connection, topology, err := brokerFromEnv()
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if connection.URL != "amqps://rabbit.internal/" || connection.Username != "items" || connection.PasswordFile != "/run/secrets/amqp" ||
+			connection.TLS.CAFile != "ca.crt" || connection.Heartbeat != 30*time.Second {
+			t.Errorf("Unexpected connection settings: %+v", connection)
+		}
+		if topology.Exchange != "items" || topology.Queue != "acme_events" || topology.DeadLetterExchange != "items.dlx" {
+			t.Errorf("Unexpected topology: %+v", topology)
+		}
+	})
+
+	t.Run("InvalidHeartbeat", func(t *testing.T) {
+		t.Setenv("AMQP_HEARTBEAT", "often")
+		if _, _, err := brokerFromEnv(); err == nil {
+			t.Error("Expected error for an invalid heartbeat")
+		}
+	})
+}
+
+func TestSpanExporter(t *testing.T) {
+	for _, kind := range []string{"", "none", "stdout"} {
+		if export, err := spanExporter(kind); export == nil || err != nil {
+			t.Errorf("Expected exporter for %q; got %v", kind, err)
+		}
+	}
+	if _, err := spanExporter("zipkin"); err == nil {
+		t.Error("Expected error for unknown exporter")
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+// tenantHeader selects the tenant, as on the server
+const tenantHeader = "X-Tenant-ID"
+
+// sequenceHeader and minSequenceParam carry change sequences, as on the server
+const (
+	sequenceHeader   = "X-Sequence"
+	minSequenceParam = "min-sequence"
+)
+
+// projectedItem is a replicated item with the version of the last event
+// applied to it. Deleted items are kept as tombstones so late events about
+// them are still recognised as stale.
+type projectedItem struct {
+	Item    events.Item `json:"item"`
+	Version int         `json:"version"`
+	Deleted bool        `json:"deleted,omitempty"`
+}
+
+// itemsSnapshot is the body of the server's GET /items/snapshot
+type itemsSnapshot struct {
+	Sequence int64           `json:"sequence"`
+	Epoch    int64           `json:"epoch"`
+	TenantID string          `json:"tenantId"`
+	Items    []projectedItem `json:"items"`
+}
+
+// projectionState is the persisted replica
+type projectionState struct {
+	// Epoch is the server store the replica was built from. Item versions
+	// and sequences start over when the server restarts with a new store.
+	Epoch   int64                            `json:"epoch,omitempty"`
+	Tenants map[string]map[int]projectedItem `json:"tenants"`
+	// Snapshots is the sequence of each tenant's last snapshot; events up
+	// to it are already reflected
+	Snapshots map[string]int64 `json:"snapshots"`
+	// Positions tracks the changes received of each tenant, which the
+	// server numbers separately
+	Positions map[string]*tenantPosition `json:"positions"`
+}
+
+// tenantPosition is how far the replica of a tenant reflects its changes
+type tenantPosition struct {
+	// Sequence is the greatest change sequence up to which every event
+	// has been received or is reflected by a snapshot. Requeued events
+	// arrive out of order, so the sequences received beyond a gap wait in
+	// Pending until it is filled. Changes before the first event received
+	// are not on the queue and count as received.
+	Sequence int64   `json:"sequence"`
+	Pending  []int64 `json:"pending,omitempty"`
+}
+
+// maxPendingSequences bounds the sequences held back behind a gap. A gap
+// this old is taken to be an event that was never published, and skipped.
+const maxPendingSequences = 1000
+
+// Projection is a local replica of the server's items, maintained from item
+// events and persisted to a file after every change
+type Projection struct {
+	mu    sync.RWMutex
+	path  string
+	state projectionState
+	// changed is closed and replaced whenever a tenant's position advances
+	changed chan struct{}
+}
+
+// LoadProjection reads the replica persisted at path, starting empty when
+// the file does not exist
+func LoadProjection(path string) (*Projection, error) {
+	p := &Projection{path: path, changed: make(chan struct{}), state: projectionState{
+		Tenants:   map[string]map[int]projectedItem{},
+		Snapshots: map[string]int64{},
+		Positions: map[string]*tenantPosition{},
+	}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read projection: %w", err)
+	}
+	if err := json.Unmarshal(data, &p.state); err != nil {
+		return nil, fmt.Errorf("failed to decode projection %s: %w", path, err)
+	}
+	if p.state.Positions == nil {
+		p.state.Positions = map[string]*tenantPosition{}
+	}
+	return p, nil
+}
+
+// Apply applies event to the replica and reports whether it changed it.
+// Events already reflected by the tenant's snapshot and events whose item
+// version is not newer than the applied one are ignored, so redelivered
+// and out-of-order events cannot roll an item back.
+func (p *Projection) Apply(event events.ItemEvent) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tenant := event.TenantID
+	if tenant == "" {
+		tenant = events.DefaultTenant
+	}
+	if !p.useEpochLocked(event.Epoch) {
+		return false
+	}
+	p.receiveLocked(tenant, event.Sequence)
+	if event.Sequence != 0 && event.Sequence <= p.state.Snapshots[tenant] {
+		return false
+	}
+	items := p.state.Tenants[tenant]
+	if items == nil {
+		items = map[int]projectedItem{}
+		p.state.Tenants[tenant] = items
+	}
+	current, ok := items[event.Item.ID]
+	if ok && event.ItemVersion != 0 && event.ItemVersion <= current.Version {
+		return false
+	}
+
+	switch event.Type {
+	case events.EventItemCreated, events.EventItemUpdated, events.EventItemRestored:
+		items[event.Item.ID] = projectedItem{Item: event.Item, Version: event.ItemVersion}
+	case events.EventItemDeleted, events.EventItemPurged:
+		items[event.Item.ID] = projectedItem{Item: event.Item, Version: event.ItemVersion, Deleted: true}
+	default:
+		return false
+	}
+	return true
+}
+
+// ApplySnapshot replaces the replica of the snapshot's tenant, so that only
+// events after the snapshot's sequence are applied to it. The tenant's
+// position moves up to the snapshot, closing any gap before it.
+func (p *Projection) ApplySnapshot(snapshot itemsSnapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.useEpochLocked(snapshot.Epoch) {
+		log.Printf("Ignoring snapshot of tenant %s from an earlier server epoch", snapshot.TenantID)
+		return
+	}
+	items := make(map[int]projectedItem, len(snapshot.Items))
+	for _, item := range snapshot.Items {
+		items[item.Item.ID] = item
+	}
+	p.state.Tenants[snapshot.TenantID] = items
+	p.state.Snapshots[snapshot.TenantID] = snapshot.Sequence
+	pos := p.positionForLocked(snapshot.TenantID)
+	pos.Sequence = max(pos.Sequence, snapshot.Sequence)
+	pos.Pending = slices.DeleteFunc(pos.Pending, func(n int64) bool { return n <= pos.Sequence })
+	pos.advance()
+	p.notifyLocked()
+}
+
+// useEpochLocked reports whether data from server epoch may be applied. A
+// newer epoch means the server started over with a new store, so the
+// replica, snapshots and positions derived from the old one are discarded.
+// Data from an earlier epoch is stale. Zero is a server without epochs.
+func (p *Projection) useEpochLocked(epoch int64) bool {
+	switch {
+	case epoch == 0 || epoch == p.state.Epoch:
+		return true
+	case epoch < p.state.Epoch:
+		return false
+	}
+	if p.state.Epoch != 0 {
+		log.Printf("Server epoch changed from %d to %d, discarding the replica", p.state.Epoch, epoch)
+		p.state.Tenants = map[string]map[int]projectedItem{}
+		p.state.Snapshots = map[string]int64{}
+		p.state.Positions = map[string]*tenantPosition{}
+		p.notifyLocked()
+	}
+	p.state.Epoch = epoch
+	return true
+}
+
+// positionForLocked returns the position of tenant, creating it on first use
+func (p *Projection) positionForLocked(tenant string) *tenantPosition {
+	pos, ok := p.state.Positions[tenant]
+	if !ok {
+		pos = &tenantPosition{}
+		p.state.Positions[tenant] = pos
+	}
+	return pos
+}
+
+// receiveLocked records that the event of change n of tenant has been
+// received and advances the tenant's position over the changes received
+// without a gap
+func (p *Projection) receiveLocked(tenant string, n int64) {
+	pos := p.positionForLocked(tenant)
+	if n <= pos.Sequence || slices.Contains(pos.Pending, n) {
+		return
+	}
+	if pos.Sequence == 0 && len(pos.Pending) == 0 {
+		pos.Sequence = n - 1
+	}
+	pos.Pending = append(pos.Pending, n)
+	slices.Sort(pos.Pending)
+	if len(pos.Pending) > maxPendingSequences {
+		log.Printf("Skipping changes %d to %d of tenant %s that were never received", pos.Sequence+1, pos.Pending[0]-1, tenant)
+		pos.Sequence = pos.Pending[0] - 1
+	}
+	if pos.advance() {
+		p.notifyLocked()
+	}
+}
+
+// advance moves Sequence over the pending changes that follow it without a
+// gap and reports whether it moved
+func (pos *tenantPosition) advance() bool {
+	advanced := false
+	for len(pos.Pending) > 0 && pos.Pending[0] == pos.Sequence+1 {
+		pos.Sequence++
+		pos.Pending = pos.Pending[1:]
+		advanced = true
+	}
+	return advanced
+}
+
+// notifyLocked wakes readers waiting in WaitForSequence
+func (p *Projection) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// positionLocked is the sequence of the latest change reflected by the
+// replica of tenant
+func (p *Projection) positionLocked(tenant string) int64 {
+	if pos, ok := p.state.Positions[tenant]; ok {
+		return pos.Sequence
+	}
+	return 0
+}
+
+// WaitForSequence blocks until the replica of tenant reflects change n or
+// ctx ends
+func (p *Projection) WaitForSequence(ctx context.Context, tenant string, n int64) error {
+	for {
+		p.mu.RLock()
+		position, changed := p.positionLocked(tenant), p.changed
+		p.mu.RUnlock()
+		if position >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("sequence %d not applied, at %d: %w", n, position, ctx.Err())
+		}
+	}
+}
+
+// Items returns the items of tenant that are not deleted, ordered by ID
+func (p *Projection) Items(tenant string) []events.Item {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	list := []events.Item{}
+	for _, projected := range p.state.Tenants[tenant] {
+		if !projected.Deleted {
+			list = append(list, projected.Item)
+		}
+	}
+	slices.SortFunc(list, func(a, b events.Item) int { return a.ID - b.ID })
+	return list
+}
+
+// Item returns the item id of tenant unless it is missing or deleted
+func (p *Projection) Item(tenant string, id int) (events.Item, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	projected, ok := p.state.Tenants[tenant][id]
+	return projected.Item, ok && !projected.Deleted
+}
+
+// Save persists the replica, replacing the file atomically so a crash
+// leaves either the previous or the new state
+func (p *Projection) Save() error {
+	p.mu.RLock()
+	data, err := json.MarshalIndent(p.state, "", "  ")
+	p.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode projection: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save projection: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save projection: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save projection: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p.path); err != nil {
+		return fmt.Errorf("failed to save projection: %w", err)
+	}
+	return nil
+}
+
+// HandleEvent applies event and persists the replica when it changed. A
+// failed save requeues the event.
+func (p *Projection) HandleEvent(_ context.Context, event events.ItemEvent) error {
+	if !p.Apply(event) {
+		return nil
+	}
+	return p.Save()
+}
+
+// Bootstrap replaces the replica of each tenant with its snapshot from the
+// server at baseURL, authenticating with apiKey. Events queued meanwhile are
+// applied only when they are newer than the snapshot.
+func (p *Projection) Bootstrap(ctx context.Context, client *http.Client, baseURL, apiKey string, tenants []string) error {
+	for _, tenant := range tenants {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/items/snapshot", nil)
+		if err != nil {
+			return fmt.Errorf("failed to create bootstrap request: %w", err)
+		}
+		req.Header.Set("X-API-Key", apiKey)
+		req.Header.Set(tenantHeader, tenant)
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to fetch snapshot of tenant %q: %w", tenant, err)
+		}
+		var snapshot itemsSnapshot
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("unexpected status %s", resp.Status)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&snapshot)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to fetch snapshot of tenant %q: %w", tenant, err)
+		}
+		p.ApplySnapshot(snapshot)
+	}
+	return p.Save()
+}
+
+// Handler serves the replica read-only: GET /items lists the items of the
+// tenant named by X-Tenant-ID and GET /items/{id} returns one. Responses
+// carry the sequence they reflect; with min-sequence a read waits up to
+// timeout for that change and fails with 503 otherwise.
+func (p *Projection) Handler(timeout time.Duration) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items", p.withMinSequence(timeout, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.Items(requestTenant(r)))
+	}))
+	mux.HandleFunc("GET /items/{id}", p.withMinSequence(timeout, func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid item ID", http.StatusBadRequest)
+			return
+		}
+		item, ok := p.Item(requestTenant(r), id)
+		if !ok {
+			http.Error(w, "Item not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(item)
+	}))
+	return mux
+}
+
+// withMinSequence waits for the change given as min-sequence before calling
+// next and reports the tenant's position in the sequence header
+func (p *Projection) withMinSequence(timeout time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := requestTenant(r)
+		if param := r.URL.Query().Get(minSequenceParam); param != "" {
+			n, err := strconv.ParseInt(param, 10, 64)
+			if err != nil || n < 0 {
+				http.Error(w, minSequenceParam+" must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			if err := p.WaitForSequence(ctx, tenant, n); err != nil {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, fmt.Sprintf("change %d has not been applied within %s", n, timeout), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		p.mu.RLock()
+		w.Header().Set(sequenceHeader, strconv.FormatInt(p.positionLocked(tenant), 10))
+		p.mu.RUnlock()
+		next(w, r)
+	}
+}
+
+// requestTenant returns the tenant named by the request, or the default
+func requestTenant(r *http.Request) string {
+	if tenant := r.Header.Get(tenantHeader); tenant != "" {
+		return tenant
+	}
+	return events.DefaultTenant
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+// newProjection returns an empty projection persisted in a temporary directory
+func newProjection(t *testing.T) *Projection {
+	t.Helper()
+	p, err := LoadProjection(filepath.Join(t.TempDir(), "items.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return p
+}
+
+// itemEvent builds an event about item version of tenant acme
+func itemEvent(eventType events.EventType, version int, name string) events.ItemEvent {
+	return events.ItemEvent{Type: eventType, TenantID: "acme", Item: events.Item{ID: 1, Name: name}, ItemVersion: version}
+}
+
+func TestProjectionApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []events.ItemEvent
+		wantName string
+		wantOK   bool
+	}{
+		{"InOrder", []events.ItemEvent{
+			itemEvent(events.EventItemCreated, 1, "a"),
+			itemEvent(events.EventItemUpdated, 2, "b"),
+		}, "b", true},
+		{"OutOfOrderUpdate", []events.ItemEvent{
+			itemEvent(events.EventItemCreated, 1, "a"),
+			itemEvent(events.EventItemUpdated, 3, "c"),
+			itemEvent(events.EventItemUpdated, 2, "b"),
+		}, "c", true},
+		{"UpdateAfterDelete", []events.ItemEvent{
+			itemEvent(events.EventItemCreated, 1, "a"),
+			itemEvent(events.EventItemDeleted, 3, "b"),
+			itemEvent(events.EventItemUpdated, 2, "b"),
+		}, "", false},
+		{"Restored", []events.ItemEvent{
+			itemEvent(events.EventItemCreated, 1, "a"),
+			itemEvent(events.EventItemDeleted, 2, "a"),
+			itemEvent(events.EventItemRestored, 3, "a"),
+		}, "a", true},
+		{"Purged", []events.ItemEvent{
+			itemEvent(events.EventItemCreated, 1, "a"),
+			itemEvent(events.EventItemDeleted, 2, "a"),
+			itemEvent(events.EventItemPurged, 3, "a"),
+		}, "", false},
+		{"Unversioned", []events.ItemEvent{
+			itemEvent(events.EventItemCreated, 0, "a"),
+			itemEvent(events.EventItemUpdated, 0, "b"),
+		}, "b", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			p := newProjection(t)
+
+			// Act
+			for _, event := range tt.events {
+				p.Apply(event)
+			}
+
+			// Assert
+			item, ok := p.Item("acme", 1)
+			if ok != tt.wantOK || (ok && item.Name != tt.wantName) {
+				t.Errorf("Expected item %q (present %v); got %+v (present %v)", tt.wantName, tt.wantOK, item, ok)
+			}
+		})
+	}
+
+	t.Run("Duplicate", func(t *testing.T) {
+		p := newProjection(t)
+		p.Apply(itemEvent(events.EventItemCreated, 1, "a"))
+		if p.Apply(itemEvent(events.EventItemCreated, 1, "a")) {
+			t.Error("Expected a redelivered event to be ignored")
+		}
+	})
+}
+
+func TestProjectionPersistence(t *testing.T) {
+	// Arrange
+	p := newProjection(t)
+	p.HandleEvent(context.Background(), itemEvent(events.EventItemCreated, 1, "a"))
+	p.HandleEvent(context.Background(), itemEvent(events.EventItemDeleted, 2, "a"))
+
+	// Act
+	loaded, err := LoadProjection(p.path)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := loaded.Item("acme", 1); ok {
+		t.Error("Expected the deletion to be persisted")
+	}
+	if loaded.Apply(itemEvent(events.EventItemUpdated, 2, "late")) {
+		t.Error("Expected the persisted tombstone to reject a stale update")
+	}
+}
+
+func TestProjectionBootstrap(t *testing.T) {
+	// Arrange
+	var gotPath, gotKey, gotTenant string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotKey, gotTenant = r.URL.Path, r.Header.Get("X-API-Key"), r.Header.Get(tenantHeader)
+		w.Write([]byte(`{"sequence":10,"tenantId":"acme","items":[{"item":{"id":2,"name":"Anvil"},"version":3}]}`))
+	}))
+	defer server.Close()
+	p := newProjection(t)
+	p.Apply(itemEvent(events.EventItemCreated, 1, "gone"))
+
+	// Act
+	err := p.Bootstrap(context.Background(), server.Client(), server.URL, "key", []string{"acme"})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotPath != "/items/snapshot" || gotKey != "key" || gotTenant != "acme" {
+		t.Errorf("Expected a snapshot request with API key and tenant; got %s with %q and %q", gotPath, gotKey, gotTenant)
+	}
+	if items := p.Items("acme"); len(items) != 1 || items[0].Name != "Anvil" {
+		t.Errorf("Expected the snapshot's items; got %+v", items)
+	}
+
+	tests := []struct {
+		name      string
+		sequence  int64
+		version   int
+		wantApply bool
+	}{
+		{"BeforeSnapshot", 9, 1, false},
+		{"AtSnapshot", 10, 4, false},
+		{"OlderVersionAfterSnapshot", 11, 3, false},
+		{"AfterSnapshot", 11, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := events.ItemEvent{Type: events.EventItemUpdated, TenantID: "acme", Item: events.Item{ID: 2, Name: "Anvil v2"}, ItemVersion: tt.version, Sequence: tt.sequence}
+			if got := p.Apply(event); got != tt.wantApply {
+				t.Errorf("Expected applied %v; got %v", tt.wantApply, got)
+			}
+		})
+	}
+}
+
+func TestProjectionHandler(t *testing.T) {
+	// Arrange
+	p := newProjection(t)
+	p.Apply(itemEvent(events.EventItemCreated, 1, "Anvil"))
+	handler := p.Handler(10 * time.Millisecond)
+
+	tests := []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{http.MethodGet, "/items", http.StatusOK},
+		{http.MethodGet, "/items/1", http.StatusOK},
+		{http.MethodGet, "/items/2", http.StatusNotFound},
+		{http.MethodGet, "/items/x", http.StatusBadRequest},
+		{http.MethodPost, "/items", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/items/1", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			// Act
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(tenantHeader, "acme")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			// Assert
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d; got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestProjectionMinSequence(t *testing.T) {
+	// Arrange
+	p := newProjection(t)
+	p.ApplySnapshot(itemsSnapshot{Sequence: 8, TenantID: "globex"})
+	p.Apply(events.ItemEvent{Type: events.EventItemCreated, TenantID: "acme", Item: events.Item{ID: 1, Name: "Anvil"}, ItemVersion: 1, Sequence: 5})
+	p.Apply(events.ItemEvent{Type: events.EventItemCreated, TenantID: "acme", Item: events.Item{ID: 2, Name: "Rocket"}, ItemVersion: 1, Sequence: 7})
+	handler := p.Handler(20 * time.Millisecond)
+
+	tests := []struct {
+		name, tenant, query string
+		wantStatus          int
+		wantSequence        string
+	}{
+		{"Applied", "acme", "?min-sequence=5", http.StatusOK, "5"},
+		{"AfterGap", "acme", "?min-sequence=7", http.StatusServiceUnavailable, ""},
+		{"ReflectedBySnapshot", "globex", "?min-sequence=8", http.StatusOK, "8"},
+		{"Invalid", "acme", "?min-sequence=x", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			req := httptest.NewRequest(http.MethodGet, "/items"+tt.query, nil)
+			req.Header.Set(tenantHeader, tt.tenant)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			// Assert
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d; got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantSequence != "" && rec.Header().Get(sequenceHeader) != tt.wantSequence {
+				t.Errorf("Expected %s %s; got %q", sequenceHeader, tt.wantSequence, rec.Header().Get(sequenceHeader))
+			}
+		})
+	}
+
+	t.Run("GapFilledWhileWaiting", func(t *testing.T) {
+		// Arrange
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			p.Apply(events.ItemEvent{Type: events.EventItemUpdated, TenantID: "acme", Item: events.Item{ID: 9}, Sequence: 6})
+		}()
+
+		// Act
+		err := p.WaitForSequence(ctx, "acme", 7)
+
+		// Assert
+		if err != nil {
+			t.Errorf("Expected the wait to end once change 6 fills the gap; got %v", err)
+		}
+		if pos := p.state.Positions["acme"]; pos.Sequence != 7 || len(pos.Pending) != 0 {
+			t.Errorf("Expected position 7 with nothing pending; got %+v", pos)
+		}
+	})
+}
+
+func TestProjectionGapClosedBySnapshot(t *testing.T) {
+	// Arrange: change 2 and 3 were never published
+	p := newProjection(t)
+	p.Apply(events.ItemEvent{Type: events.EventItemCreated, TenantID: "acme", Item: events.Item{ID: 1}, ItemVersion: 1, Sequence: 1})
+	p.ApplySnapshot(itemsSnapshot{Sequence: 3, TenantID: "acme", Items: []projectedItem{{Item: events.Item{ID: 1}, Version: 1}}})
+
+	// Act
+	p.Apply(events.ItemEvent{Type: events.EventItemCreated, TenantID: "acme", Item: events.Item{ID: 9}, ItemVersion: 1, Sequence: 4})
+
+	// Assert
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.WaitForSequence(ctx, "acme", 4); err != nil {
+		t.Errorf("Expected change 4 to be reflected; got %v", err)
+	}
+	if pos := p.state.Positions["acme"]; pos.Sequence != 4 || len(pos.Pending) != 0 {
+		t.Errorf("Expected position 4 with nothing pending; got %+v", pos)
+	}
+	if _, ok := p.Item("acme", 9); !ok {
+		t.Error("Expected item 9 to be applied")
+	}
+
+	t.Run("SnapshotDropsPendingBelowIt", func(t *testing.T) {
+		p.Apply(events.ItemEvent{Type: events.EventItemUpdated, TenantID: "acme", Item: events.Item{ID: 9}, ItemVersion: 2, Sequence: 6})
+		p.Apply(events.ItemEvent{Type: events.EventItemUpdated, TenantID: "acme", Item: events.Item{ID: 9}, ItemVersion: 4, Sequence: 8})
+		p.ApplySnapshot(itemsSnapshot{Sequence: 7, TenantID: "acme"})
+		if pos := p.state.Positions["acme"]; pos.Sequence != 8 || len(pos.Pending) != 0 {
+			t.Errorf("Expected position 8 with nothing pending; got %+v", pos)
+		}
+	})
+}
+
+func TestProjectionPendingIsBounded(t *testing.T) {
+	// Arrange
+	p := newProjection(t)
+	p.Apply(events.ItemEvent{Type: events.EventItemCreated, TenantID: "acme", Item: events.Item{ID: 1}, ItemVersion: 1, Sequence: 1})
+
+	// Act: change 2 never arrives
+	for n := int64(3); n <= maxPendingSequences+3; n++ {
+		p.Apply(events.ItemEvent{Type: events.EventItemUpdated, TenantID: "acme", Item: events.Item{ID: 1}, ItemVersion: int(n), Sequence: n})
+	}
+
+	// Assert
+	if pos := p.state.Positions["acme"]; pos.Sequence != maxPendingSequences+3 || len(pos.Pending) != 0 {
+		t.Errorf("Expected the gap to be skipped; got position %d with %d pending", pos.Sequence, len(pos.Pending))
+	}
+}
+
+func TestProjectionServerRestart(t *testing.T) {
+	// Arrange
+	p := newProjection(t)
+	p.ApplySnapshot(itemsSnapshot{Sequence: 3, Epoch: 100, TenantID: "acme", Items: []projectedItem{{Item: events.Item{ID: 1, Name: "Anvil"}, Version: 3}}})
+	p.Apply(events.ItemEvent{Type: events.EventItemCreated, TenantID: "globex", Item: events.Item{ID: 1}, ItemVersion: 1, Sequence: 1, Epoch: 100})
+
+	// Act: the restarted server numbers from 1 again
+	applied := p.Apply(events.ItemEvent{Type: events.EventItemCreated, TenantID: "acme", Item: events.Item{ID: 1, Name: "Rocket"}, ItemVersion: 1, Sequence: 1, Epoch: 200})
+
+	// Assert
+	if !applied {
+		t.Fatal("Expected the first event of the new epoch to be applied")
+	}
+	if item, ok := p.Item("acme", 1); !ok || item.Name != "Rocket" {
+		t.Errorf("Expected the item of the new epoch; got %+v", item)
+	}
+	if _, ok := p.Item("globex", 1); ok {
+		t.Error("Expected the replica of the old epoch to be discarded")
+	}
+	if got := p.positionLocked("acme"); got != 1 {
+		t.Errorf("Expected position 1 in the new epoch; got %d", got)
+	}
+
+	t.Run("IgnoresEarlierEpoch", func(t *testing.T) {
+		if p.Apply(events.ItemEvent{Type: events.EventItemUpdated, TenantID: "acme", Item: events.Item{ID: 1, Name: "Old"}, ItemVersion: 4, Sequence: 4, Epoch: 100}) {
+			t.Error("Expected an event of the earlier epoch to be ignored")
+		}
+		p.ApplySnapshot(itemsSnapshot{Sequence: 9, Epoch: 100, TenantID: "acme"})
+		if item, _ := p.Item("acme", 1); item.Name != "Rocket" {
+			t.Errorf("Expected stale data to be ignored; got %+v", item)
+		}
+	})
+
+	t.Run("EpochIsPersisted", func(t *testing.T) {
+		if err := p.Save(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		loaded, err := LoadProjection(p.path)
+		if err != nil || loaded.state.Epoch != 200 {
+			t.Errorf("Expected epoch 200 after reload; got %d (%v)", loaded.state.Epoch, err)
+		}
+	})
+}
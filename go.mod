@@ -2,4 +2,7 @@ module github.com/us-service/Go-server-crud
 
 go 1.24.1
 
-require github.com/rabbitmq/amqp091-go v1.10.0
+require (
+	github.com/rabbitmq/amqp091-go v1.10.0
+	github.com/swaggo/files/v2 v2.0.2
+)
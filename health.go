@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Health statuses reported by the health and readiness endpoints
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded"
+)
+
+// storeLockTimeout bounds how long the readiness probe waits for the store
+var storeLockTimeout = 100 * time.Millisecond
+
+// ComponentStatus describes the state of a single dependency
+type ComponentStatus struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Required bool   `json:"required"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HealthReport is the JSON document returned by /healthz and /readyz
+type HealthReport struct {
+	Status     string            `json:"status"`
+	Components []ComponentStatus `json:"components,omitempty"`
+	Timestamp  time.Time         `json:"timestamp"`
+}
+
+// readinessCheck is a named probe contributing to /readyz
+type readinessCheck struct {
+	name     string
+	required bool
+	check    func() error
+}
+
+// requireBroker makes a missing or closed broker connection fail readiness
+var requireBroker bool
+
+// readinessChecks returns the probes evaluated by /readyz. There is no
+// outbox backlog to check: events are published synchronously within the
+// request that made the change, never queued for later delivery.
+func readinessChecks() []readinessCheck {
+	return []readinessCheck{
+		{name: "store", required: true, check: checkStore},
+		{name: "broker", required: requireBroker, check: checkBroker},
+	}
+}
+
+// checkStore verifies the item store can be locked for writing
+func checkStore() error {
+	return store.Ping(storeLockTimeout)
+}
+
+// checkBroker verifies the event publisher has an open connection and channel
+func checkBroker() error {
+	if eventPublisher == nil {
+		return errors.New("event publisher is not initialized")
+	}
+	return eventPublisher.Ready()
+}
+
+// evaluateReadiness runs every check and aggregates the overall status
+func evaluateReadiness(checks []readinessCheck) HealthReport {
+	report := HealthReport{Status: StatusUp, Timestamp: time.Now()}
+	for _, c := range checks {
+		component := ComponentStatus{Name: c.name, Status: StatusUp, Required: c.required}
+		if err := c.check(); err != nil {
+			component.Status = StatusDown
+			component.Error = err.Error()
+			if c.required {
+				report.Status = StatusDown
+			} else if report.Status == StatusUp {
+				report.Status = StatusDegraded
+			}
+		}
+		report.Components = append(report.Components, component)
+	}
+	return report
+}
+
+// healthz reports that the process is alive
+func healthz(w http.ResponseWriter) {
+	writeHealthReport(w, HealthReport{Status: StatusUp, Timestamp: time.Now()})
+}
+
+// readyz reports whether the instance can serve traffic
+func readyz(w http.ResponseWriter) {
+	writeHealthReport(w, evaluateReadiness(readinessChecks()))
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == StatusDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+func TestHealthz(t *testing.T) {
+	// Arrange
+	rec := httptest.NewRecorder()
+
+	// Act
+	healthz(rec)
+
+	// Assert
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status OK; got %v", rec.Code)
+	}
+	var got HealthReport
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if got.Status != StatusUp {
+		t.Errorf("Expected status %s; got %s", StatusUp, got.Status)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	t.Run("BrokerOptional", func(t *testing.T) {
+		// Arrange
+		eventPublisher = nil
+		requireBroker = false
+		rec := httptest.NewRecorder()
+
+		// Act
+		readyz(rec)
+
+		// Assert
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status OK; got %v", rec.Code)
+		}
+		var got HealthReport
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if got.Status != StatusDegraded {
+			t.Errorf("Expected status %s; got %s", StatusDegraded, got.Status)
+		}
+		if len(got.Components) != 2 {
+			t.Fatalf("Expected 2 components; got %v", got.Components)
+		}
+	})
+
+	t.Run("BrokerRequired", func(t *testing.T) {
+		// Arrange
+		eventPublisher = &events.Publisher{}
+		requireBroker = true
+		defer func() {
+			eventPublisher = nil
+			requireBroker = false
+		}()
+		rec := httptest.NewRecorder()
+
+		// Act
+		readyz(rec)
+
+		// Assert
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status Service Unavailable; got %v", rec.Code)
+		}
+	})
+
+	t.Run("StoreLocked", func(t *testing.T) {
+		// Arrange
+		useStore(t, 0)
+		store.mu.Lock()
+		defer store.mu.Unlock()
+
+		// Act
+		err := checkStore()
+
+		// Assert
+		if err == nil {
+			t.Error("Expected error when store is locked")
+		}
+	})
+}
+
+func TestEvaluateReadiness(t *testing.T) {
+	failing := func() error { return errors.New("boom") }
+	passing := func() error { return nil }
+
+	got := evaluateReadiness([]readinessCheck{
+		{name: "a", required: true, check: passing},
+		{name: "b", required: false, check: failing},
+	})
+	if got.Status != StatusDegraded {
+		t.Errorf("Expected status %s; got %s", StatusDegraded, got.Status)
+	}
+	if got.Components[1].Error != "boom" {
+		t.Errorf("Expected component error to be reported; got %+v", got.Components[1])
+	}
+
+	got = evaluateReadiness([]readinessCheck{
+		{name: "a", required: true, check: failing},
+		{name: "b", required: false, check: failing},
+	})
+	if got.Status != StatusDown {
+		t.Errorf("Expected status %s; got %s", StatusDown, got.Status)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWTClaims are the registered and application claims read from a token
+type JWTClaims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt int64       `json:"exp"`
+	NotBefore int64       `json:"nbf"`
+	IssuedAt  int64       `json:"iat"`
+	Roles     []string    `json:"roles"`
+	Tenant    string      `json:"tenant"`
+}
+
+// jwtAudience accepts the aud claim as a string or an array of strings
+type jwtAudience []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (a *jwtAudience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = jwtAudience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = many
+	return nil
+}
+
+// JWTVerifier validates HMAC or RSA signed bearer tokens
+type JWTVerifier struct {
+	hmacSecret []byte
+	rsaKey     *rsa.PublicKey
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	now        func() time.Time
+}
+
+// NewJWTVerifier creates a verifier from configuration, or returns nil when
+// no signing key is configured
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	if cfg.HMACSecret == "" && cfg.RSAPublicKeyFile == "" {
+		return nil, nil
+	}
+	v := &JWTVerifier{
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		leeway:   cfg.Leeway.Duration,
+		now:      time.Now,
+	}
+	if cfg.HMACSecret != "" {
+		v.hmacSecret = []byte(cfg.HMACSecret)
+	}
+	if cfg.RSAPublicKeyFile != "" {
+		key, err := loadRSAPublicKey(cfg.RSAPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.rsaKey = key
+	}
+	return v, nil
+}
+
+// loadRSAPublicKey reads a PEM encoded PKIX or PKCS#1 RSA public key
+func loadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RSA public key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("RSA public key file is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return key, nil
+}
+
+// jwtHashes maps supported algorithms to their digest
+var jwtHashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+}
+
+// Verify checks the token signature and its time, issuer and audience claims
+func (v *JWTVerifier) Verify(token string) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	hash, ok := jwtHashes[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("invalid token signature encoding")
+	}
+	signingInput := parts[0] + "." + parts[1]
+	if err := v.verifySignature(header.Alg, hash, signingInput, signature); err != nil {
+		return nil, err
+	}
+
+	var claims JWTClaims
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	if err := v.validateClaims(&claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (v *JWTVerifier) verifySignature(alg string, hash crypto.Hash, signingInput string, signature []byte) error {
+	switch alg[:2] {
+	case "HS":
+		if v.hmacSecret == nil {
+			return fmt.Errorf("algorithm %s is not accepted", alg)
+		}
+		mac := hmac.New(hash.New, v.hmacSecret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errors.New("invalid token signature")
+		}
+	case "RS":
+		if v.rsaKey == nil {
+			return fmt.Errorf("algorithm %s is not accepted", alg)
+		}
+		h := hash.New()
+		h.Write([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(v.rsaKey, hash, h.Sum(nil), signature); err != nil {
+			return errors.New("invalid token signature")
+		}
+	}
+	return nil
+}
+
+func (v *JWTVerifier) validateClaims(claims *JWTClaims) error {
+	now := v.now()
+	if claims.Subject == "" {
+		return errors.New("token has no subject")
+	}
+	if claims.ExpiresAt == 0 {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(v.leeway)) {
+		return errors.New("token has expired")
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-v.leeway)) {
+		return errors.New("token is not valid yet")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return errors.New("token issuer is not accepted")
+	}
+	if v.audience != "" {
+		for _, aud := range claims.Audience {
+			if aud == v.audience {
+				return nil
+			}
+		}
+		return errors.New("token audience is not accepted")
+	}
+	return nil
+}
+
+func decodeJWTSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// looksLikeJWT reports whether a bearer credential has the three-segment JWT shape
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
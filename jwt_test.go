@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// signTestJWT builds a token for claims using alg and key
+func signTestJWT(t *testing.T, alg string, key any, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("Could not sign token: %v", err)
+		}
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":   "alice",
+		"iss":   "https://issuer.example",
+		"aud":   []string{"items-api"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"editor"},
+	}
+}
+
+func TestJWTVerifierHMAC(t *testing.T) {
+	secret := []byte("test-secret")
+	verifier, err := NewJWTVerifier(JWTConfig{
+		HMACSecret: string(secret),
+		Issuer:     "https://issuer.example",
+		Audience:   "items-api",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		claims, err := verifier.Verify(signTestJWT(t, "HS256", secret, validClaims()))
+		if err != nil {
+			t.Fatalf("Expected valid token; got %v", err)
+		}
+		if claims.Subject != "alice" || len(claims.Roles) != 1 || claims.Roles[0] != "editor" {
+			t.Errorf("Unexpected claims: %+v", claims)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		mutate func(map[string]any)
+		key    []byte
+		alg    string
+	}{
+		{"Expired", func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, secret, "HS256"},
+		{"MissingExpiry", func(c map[string]any) { delete(c, "exp") }, secret, "HS256"},
+		{"NotYetValid", func(c map[string]any) { c["nbf"] = time.Now().Add(time.Hour).Unix() }, secret, "HS256"},
+		{"WrongIssuer", func(c map[string]any) { c["iss"] = "https://evil.example" }, secret, "HS256"},
+		{"WrongAudience", func(c map[string]any) { c["aud"] = "other-api" }, secret, "HS256"},
+		{"MissingSubject", func(c map[string]any) { delete(c, "sub") }, secret, "HS256"},
+		{"WrongSecret", func(map[string]any) {}, []byte("other-secret"), "HS256"},
+		{"AlgNone", func(map[string]any) {}, secret, "none"},
+		{"RSANotConfigured", func(map[string]any) {}, secret, "RS256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.mutate(claims)
+			if _, err := verifier.Verify(signTestJWT(t, tt.alg, tt.key, claims)); err == nil {
+				t.Error("Expected token to be rejected")
+			}
+		})
+	}
+
+	t.Run("AudienceAsString", func(t *testing.T) {
+		claims := validClaims()
+		claims["aud"] = "items-api"
+		if _, err := verifier.Verify(signTestJWT(t, "HS256", secret, claims)); err != nil {
+			t.Errorf("Expected string audience to be accepted; got %v", err)
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		if _, err := verifier.Verify("a.b"); err == nil {
+			t.Error("Expected malformed token to be rejected")
+		}
+	})
+}
+
+func TestJWTVerifierRSA(t *testing.T) {
+	// Arrange
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Could not generate key: %v", err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	path := filepath.Join(t.TempDir(), "public.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Could not write key: %v", err)
+	}
+	verifier, err := NewJWTVerifier(JWTConfig{RSAPublicKeyFile: path})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Act
+	claims, err := verifier.Verify(signTestJWT(t, "RS256", key, validClaims()))
+
+	// Assert
+	if err != nil || claims.Subject != "alice" {
+		t.Errorf("Expected RS256 token to verify; got %v, %v", claims, err)
+	}
+	if _, err := verifier.Verify(signTestJWT(t, "HS256", []byte("x"), validClaims())); err == nil {
+		t.Error("Expected HS256 token to be rejected when only RSA is configured")
+	}
+}
+
+func TestNewJWTVerifierDisabled(t *testing.T) {
+	verifier, err := NewJWTVerifier(JWTConfig{})
+	if err != nil || verifier != nil {
+		t.Errorf("Expected no verifier without keys; got %v, %v", verifier, err)
+	}
+}
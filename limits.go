@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// maxBodyBytes caps request bodies; 0 disables the limit
+var maxBodyBytes int64 = 1 << 20
+
+// withBodyLimit caps the request body at maxBodyBytes
+func withBodyLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maxBodyBytes > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeErrorDetail describes why a request body could not be decoded
+func decodeErrorDetail(err error) string {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return "request body too large"
+	}
+	return "invalid input"
+}
+
+// writeDecodeError rejects a request whose body could not be decoded, with
+// 413 when it exceeded the size limit and 400 otherwise
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		return
+	}
+	http.Error(w, "Invalid input", http.StatusBadRequest)
+}
+
+// shedRetryAfter is the Retry-After sent with load shedding responses
+const shedRetryAfter = 1
+
+// unshedRoutes are never shed so probes and scrapes see an overloaded
+// server as alive
+var unshedRoutes = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// inFlight holds a slot per request being served; nil means unlimited
+var inFlight chan struct{}
+
+// newInFlightLimit returns the semaphore for max concurrent requests, or
+// nil when max is 0
+func newInFlightLimit(max int) chan struct{} {
+	if max <= 0 {
+		return nil
+	}
+	return make(chan struct{}, max)
+}
+
+// withConcurrencyLimit sheds requests with a 503 problem response while the
+// server is already serving its maximum number of requests
+func withConcurrencyLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slots := inFlight
+		if slots == nil || unshedRoutes[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		default:
+			httpRequestsShedTotal.Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(shedRetryAfter))
+			writeProblem(w, r, http.StatusServiceUnavailable, "server is at capacity, retry later")
+		}
+	})
+}
+
+// newHTTPServer creates the HTTP server with the configured timeouts
+func newHTTPServer(cfg ServerConfig, h http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout.Duration,
+		ReadTimeout:       cfg.ReadTimeout.Duration,
+		WriteTimeout:      cfg.WriteTimeout.Duration,
+		IdleTimeout:       cfg.IdleTimeout.Duration,
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBodyLimit(t *testing.T) {
+	// Arrange
+	previous := maxBodyBytes
+	maxBodyBytes = 32
+	t.Cleanup(func() { maxBodyBytes = previous })
+	l := useAuditLog(t)
+	items := useStore(t, 0)
+	eventPublisher = nil
+	body := `{"name":"` + strings.Repeat("x", 64) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/items/add", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	// Act
+	newHandler().ServeHTTP(rec, req)
+
+	// Assert
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status Request Entity Too Large; got %v", rec.Code)
+	}
+	var problem Problem
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil || problem.Detail != "request body exceeds 32 bytes" {
+		t.Errorf("Unexpected problem: %+v (%v)", problem, err)
+	}
+	if len(items.items) != 0 {
+		t.Errorf("Expected nothing to be stored; got %v", items.items)
+	}
+	if records := l.Query(AuditFilter{}); len(records) != 1 || records[0].Detail != "request body too large" {
+		t.Errorf("Expected the rejected attempt to be audited; got %+v", records)
+	}
+}
+
+func TestBodyWithinLimit(t *testing.T) {
+	// Arrange
+	previous := maxBodyBytes
+	maxBodyBytes = 32
+	t.Cleanup(func() { maxBodyBytes = previous })
+	useStore(t, 0)
+	eventPublisher = nil
+	req := httptest.NewRequest(http.MethodPost, "/items/add", strings.NewReader(`{"name":"ok"}`))
+	rec := httptest.NewRecorder()
+
+	// Act
+	newHandler().ServeHTTP(rec, req)
+
+	// Assert
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected status Created; got %v", rec.Code)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	// Arrange
+	previous := inFlight
+	inFlight = newInFlightLimit(1)
+	t.Cleanup(func() { inFlight = previous })
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	h := withConcurrencyLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+	go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+	<-entered
+	shedBefore := httpRequestsShedTotal.Value()
+
+	// Act
+	shed := httptest.NewRecorder()
+	h.ServeHTTP(shed, httptest.NewRequest(http.MethodGet, "/items", nil))
+	probe := httptest.NewRecorder()
+	withConcurrencyLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(probe, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	close(release)
+
+	// Assert
+	if shed.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status Service Unavailable; got %v", shed.Code)
+	}
+	if got := shed.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Expected Retry-After 1; got %q", got)
+	}
+	if got := httpRequestsShedTotal.Value(); got != shedBefore+1 {
+		t.Errorf("Expected shed request to be counted; got %v -> %v", shedBefore, got)
+	}
+	if probe.Code != http.StatusOK {
+		t.Errorf("Expected probes to bypass the limit; got %v", probe.Code)
+	}
+}
+
+func TestNewHTTPServerTimeouts(t *testing.T) {
+	cfg := defaultConfig().Server
+	srv := newHTTPServer(cfg, http.NotFoundHandler())
+	if srv.ReadHeaderTimeout != 5*time.Second || srv.ReadTimeout != 15*time.Second ||
+		srv.WriteTimeout != 30*time.Second || srv.IdleTimeout != 60*time.Second {
+		t.Errorf("Unexpected timeouts: %+v", srv)
+	}
+}
+
+func TestReadHeaderTimeoutClosesSlowClients(t *testing.T) {
+	// Arrange
+	cfg := defaultConfig().Server
+	cfg.ReadHeaderTimeout = Duration{50 * time.Millisecond}
+	srv := newHTTPServer(cfg, http.NotFoundHandler())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	defer conn.Close()
+
+	// Act: send an incomplete request line and stall
+	conn.Write([]byte("GET /items HTTP/1.1\r\nHost: x\r\n"))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = bufio.NewReader(conn).ReadString('\n')
+
+	// Assert
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Error("Expected the server to close a connection that stalls sending headers")
+	}
+}
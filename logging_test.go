@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithRequestID(t *testing.T) {
+	var seen string
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestIDFromContext(r.Context())
+	}))
+
+	t.Run("AcceptsClientID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req.Header.Set(RequestIDHeader, "client-id-123")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if seen != "client-id-123" {
+			t.Errorf("Expected client request ID in context; got %q", seen)
+		}
+		if got := rec.Header().Get(RequestIDHeader); got != "client-id-123" {
+			t.Errorf("Expected request ID to be echoed; got %q", got)
+		}
+	})
+
+	t.Run("GeneratesWhenMissingOrInvalid", func(t *testing.T) {
+		for _, id := range []string{"", "has space", strings.Repeat("x", maxRequestIDLength+1)} {
+			req := httptest.NewRequest(http.MethodGet, "/items", nil)
+			req.Header.Set(RequestIDHeader, id)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if seen == "" || seen == id {
+				t.Errorf("Expected a generated request ID for %q; got %q", id, seen)
+			}
+			if got := rec.Header().Get(RequestIDHeader); got != seen {
+				t.Errorf("Expected generated ID %q in response; got %q", seen, got)
+			}
+		}
+	})
+}
+
+func TestContextHandlerAddsRequestID(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, LogConfig{Level: "info", Format: "json"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx := withRequestIDContext(context.Background(), "req-1")
+
+	// Act
+	logger.With("component", "test").InfoContext(ctx, "hello")
+	logger.DebugContext(ctx, "filtered out")
+
+	// Assert
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a single JSON record; got %q: %v", buf.String(), err)
+	}
+	if record["request_id"] != "req-1" || record["component"] != "test" || record["msg"] != "hello" {
+		t.Errorf("Unexpected log record: %v", record)
+	}
+}
+
+func TestNewLoggerValidation(t *testing.T) {
+	if _, err := newLogger(&bytes.Buffer{}, LogConfig{Level: "loud", Format: "json"}); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+	if _, err := newLogger(&bytes.Buffer{}, LogConfig{Level: "info", Format: "xml"}); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+	if _, err := newLogger(&bytes.Buffer{}, LogConfig{Level: "WARN", Format: "text"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestWithAccessLog(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	logger, _ := newLogger(&buf, LogConfig{Level: "info", Format: "json"})
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+	handler := withRequestID(withAccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})))
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set(RequestIDHeader, "req-2")
+
+	// Act
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Assert
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Could not decode access log: %v", err)
+	}
+	if record["request_id"] != "req-2" || record["status"] != float64(http.StatusTeapot) || record["path"] != "/items" {
+		t.Errorf("Unexpected access log record: %v", record)
+	}
+}
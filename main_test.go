@@ -10,15 +10,15 @@ import (
 
 func TestGetItems(t *testing.T) {
 	// Arrange
-	items = []Item{{ID: 1, Name: "Test Item"}}
-	_, err := http.NewRequest(http.MethodGet, "/items", nil)
+	useStore(t, 0, Item{ID: 1, Name: "Test Item"})
+	req, err := http.NewRequest(http.MethodGet, "/items", nil)
 	if err != nil {
 		t.Fatalf("Could not create request: %v", err)
 	}
 	rec := httptest.NewRecorder()
 
 	// Act
-	getItems(rec)
+	getItems(rec, req)
 
 	// Assert
 	if rec.Code != http.StatusOK {
@@ -35,8 +35,7 @@ func TestGetItems(t *testing.T) {
 
 func TestAddItem(t *testing.T) {
 	// Arrange
-	items = []Item{}
-	nextID = 1
+	items := useStore(t, 0)
 	newItem := Item{Name: "New Item"}
 	body, err := json.Marshal(newItem)
 	if err != nil {
@@ -63,13 +62,13 @@ func TestAddItem(t *testing.T) {
 	if got.ID != 1 || got.Name != "New Item" {
 		t.Errorf("Unexpected response: %v", got)
 	}
-	if len(items) != 1 || items[0].Name != "New Item" {
-		t.Errorf("Item was not added correctly: %v", items)
+	if len(items.items) != 1 || items.items[0].Name != "New Item" {
+		t.Errorf("Item was not added correctly: %v", items.items)
 	}
 }
 func TestUpdateItem(t *testing.T) {
 	// Arrange
-	items = []Item{{ID: 1, Name: "Old Item"}}
+	items := useStore(t, 0, Item{ID: 1, Name: "Old Item"})
 	updatedItem := Item{ID: 1, Name: "Updated Item"}
 	body, err := json.Marshal(updatedItem)
 	if err != nil {
@@ -96,14 +95,14 @@ func TestUpdateItem(t *testing.T) {
 	if got.ID != 1 || got.Name != "Updated Item" {
 		t.Errorf("Unexpected response: %v", got)
 	}
-	if len(items) != 1 || items[0].Name != "Updated Item" {
-		t.Errorf("Item was not updated correctly: %v", items)
+	if len(items.items) != 1 || items.items[0].Name != "Updated Item" {
+		t.Errorf("Item was not updated correctly: %v", items.items)
 	}
 }
 
 func TestDeleteItem(t *testing.T) {
 	// Arrange
-	items = []Item{{ID: 1, Name: "Item to Delete"}}
+	items := useStore(t, 0, Item{ID: 1, Name: "Item to Delete"})
 	itemToDelete := Item{ID: 1}
 	body, err := json.Marshal(itemToDelete)
 	if err != nil {
@@ -127,10 +126,16 @@ func TestDeleteItem(t *testing.T) {
 	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
 		t.Fatalf("Could not decode response: %v", err)
 	}
-	if got.ID != 1 || got.Name != "Item to Delete" {
+	if got.ID != 1 || got.Name != "Item to Delete" || got.DeletedAt == nil {
 		t.Errorf("Unexpected response: %v", got)
 	}
-	if len(items) != 0 {
-		t.Errorf("Item was not deleted correctly: %v", items)
+	if len(items.items) != 1 || items.items[0].DeletedAt == nil {
+		t.Errorf("Expected the item to be kept in the trash; got %v", items.items)
+	}
+	if listed := items.list(false); len(listed) != 0 {
+		t.Errorf("Expected the deleted item to be hidden; got %v", listed)
+	}
+	if items.versions[1] != 2 {
+		t.Errorf("Expected the deletion to bump the item version to 2; got %d", items.versions[1])
 	}
 }
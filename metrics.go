@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultBuckets are the latency histogram bounds in seconds
+var defaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// collector is anything that can write itself in Prometheus text format
+type collector interface {
+	writeTo(w io.Writer)
+}
+
+// MetricsRegistry holds every metric exposed on /metrics
+type MetricsRegistry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func (r *MetricsRegistry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Write writes all registered metrics in registration order
+func (r *MetricsRegistry) Write(w io.Writer) {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	for _, c := range collectors {
+		c.writeTo(w)
+	}
+}
+
+// CounterVec is a monotonically increasing counter partitioned by labels
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(r *MetricsRegistry, name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	r.register(c)
+	return c
+}
+
+// Inc increments the counter for the given label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta to the counter for the given label values
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	key := labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] += delta
+}
+
+// Value returns the current counter value for the given label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[labelKey(labelValues)]
+}
+
+func (c *CounterVec) writeTo(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, key, ""), formatFloat(c.values[key]))
+	}
+}
+
+// HistogramVec tracks observations in cumulative buckets partitioned by labels
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(r *MetricsRegistry, name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogramSeries)}
+	r.register(h)
+	return h
+}
+
+// Observe records a value for the given label values
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// Count returns the number of observations for the given label values
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[labelKey(labelValues)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) writeTo(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			le := `le="` + formatFloat(bound) + `"`
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, le), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, `le="+Inf"`), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, ""), s.count)
+	}
+}
+
+// GaugeFunc is a gauge whose value is sampled at scrape time
+type GaugeFunc struct {
+	name  string
+	help  string
+	value func() float64
+}
+
+func newGaugeFunc(r *MetricsRegistry, name, help string, value func() float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, value: value}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) writeTo(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, g.help, g.name, g.name, formatFloat(g.value()))
+}
+
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels renders a label set, appending extra (already formatted) if set
+func formatLabels(names []string, key string, extra string) string {
+	var pairs []string
+	if len(names) > 0 {
+		values := strings.Split(key, "\xff")
+		for i, name := range names {
+			value := ""
+			if i < len(values) {
+				value = values[i]
+			}
+			pairs = append(pairs, name+`="`+escapeLabelValue(value)+`"`)
+		}
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Application metrics
+var (
+	metrics = &MetricsRegistry{}
+
+	httpRequestsTotal = newCounterVec(metrics, "http_requests_total",
+		"Total HTTP requests by route, method and status.", "route", "method", "status")
+	httpRequestDuration = newHistogramVec(metrics, "http_request_duration_seconds",
+		"HTTP request latency by route and status.", defaultBuckets, "route", "status")
+
+	eventsPublishedTotal = newCounterVec(metrics, "events_published_total",
+		"Events published to RabbitMQ by outcome.", "outcome")
+	eventPublishDuration = newHistogramVec(metrics, "event_publish_duration_seconds",
+		"Latency of publishing an event to RabbitMQ.", defaultBuckets)
+
+	eventsConsumedTotal = newCounterVec(metrics, "events_consumed_total",
+		"Events consumed from RabbitMQ by outcome (processed, nacked, requeued).", "outcome")
+	eventsUpcastTotal = newCounterVec(metrics, "events_upcast_total",
+		"Consumed events upcast from an older schema version by that version.", "version")
+	eventHandlerPanicsTotal = newCounterVec(metrics, "event_handler_panics_total",
+		"Event handlers that panicked by event type.", "event_type")
+
+	itemsPurgedTotal = newCounterVec(metrics, "items_purged_total",
+		"Deleted items removed from the trash by the purge job.")
+
+	authorizationDeniedTotal = newCounterVec(metrics, "authorization_denied_total",
+		"Requests rejected by the authorization policy by permission.", "permission")
+
+	httpRequestsShedTotal = newCounterVec(metrics, "http_requests_shed_total",
+		"Requests rejected because the server was at its concurrency limit.")
+	_ = newGaugeFunc(metrics, "http_requests_in_flight", "Requests holding a concurrency limit slot.", func() float64 {
+		return float64(len(inFlight))
+	})
+
+	rateLimitedTotal = newCounterVec(metrics, "rate_limited_total",
+		"Requests rejected by the rate limiter by route class.", "class")
+
+	auditRecordsTotal = newCounterVec(metrics, "audit_records_total",
+		"Audit records by outcome (success, denied, rejected), or error when writing failed.", "outcome")
+
+	_ = newGaugeFunc(metrics, "items", "Number of items in the store, not counting the trash.", func() float64 {
+		return float64(store.Count())
+	})
+	_ = newGaugeFunc(metrics, "amqp_connection_up", "Whether the publisher AMQP connection is open.", func() float64 {
+		return boolGauge(eventPublisher != nil && eventPublisher.Conn() != nil && !eventPublisher.Conn().IsClosed())
+	})
+	_ = newGaugeFunc(metrics, "amqp_channel_up", "Whether the publisher AMQP channel is open.", func() float64 {
+		return boolGauge(eventPublisher != nil && eventPublisher.Channel() != nil && !eventPublisher.Channel().IsClosed())
+	})
+)
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// methodLabel returns method for the standard HTTP methods and "other" for
+// anything else, so clients cannot create series with made-up methods
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
+
+// instrument records request count and latency for a route
+func instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		status := strconv.Itoa(rec.status)
+		httpRequestsTotal.Inc(route, methodLabel(r.Method), status)
+		httpRequestDuration.Observe(time.Since(start).Seconds(), route, status)
+	}
+}
+
+// metricsHandler serves all metrics in Prometheus text exposition format
+func metricsHandler(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.Write(w)
+}
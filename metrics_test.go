@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounterVec(t *testing.T) {
+	registry := &MetricsRegistry{}
+	counter := newCounterVec(registry, "test_total", "Test counter.", "outcome")
+
+	counter.Inc("success")
+	counter.Inc("success")
+	counter.Add(3, "failure")
+
+	if got := counter.Value("success"); got != 2 {
+		t.Errorf("Expected success count 2; got %v", got)
+	}
+	var buf bytes.Buffer
+	registry.Write(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE test_total counter",
+		`test_total{outcome="failure"} 3`,
+		`test_total{outcome="success"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q; got:\n%s", want, out)
+		}
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	registry := &MetricsRegistry{}
+	histogram := newHistogramVec(registry, "test_seconds", "Test histogram.", []float64{0.1, 1}, "route")
+
+	histogram.Observe(0.05, "/a")
+	histogram.Observe(0.5, "/a")
+	histogram.Observe(5, "/a")
+
+	var buf bytes.Buffer
+	registry.Write(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE test_seconds histogram",
+		`test_seconds_bucket{route="/a",le="0.1"} 1`,
+		`test_seconds_bucket{route="/a",le="1"} 2`,
+		`test_seconds_bucket{route="/a",le="+Inf"} 3`,
+		`test_seconds_sum{route="/a"} 5.55`,
+		`test_seconds_count{route="/a"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q; got:\n%s", want, out)
+		}
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	got := escapeLabelValue("a\"b\\c\nd")
+	want := `a\"b\\c\nd`
+	if got != want {
+		t.Errorf("Expected %q; got %q", want, got)
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	// Arrange
+	handler := instrument("/test-route", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+		http.Error(w, "Item not found", http.StatusNotFound)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/test-route", nil)
+	rec := httptest.NewRecorder()
+	before := httpRequestsTotal.Value("/test-route", http.MethodGet, "404")
+
+	// Act
+	handler(rec, req)
+
+	// Assert
+	if got := httpRequestsTotal.Value("/test-route", http.MethodGet, "404"); got != before+1 {
+		t.Errorf("Expected request counter to increase by 1; got %v -> %v", before, got)
+	}
+	if got := httpRequestDuration.Count("/test-route", "404"); got == 0 {
+		t.Error("Expected latency to be observed")
+	}
+
+	t.Run("UnknownMethod", func(t *testing.T) {
+		before := httpRequestsTotal.Value("/test-route", "other", "404")
+		handler(httptest.NewRecorder(), httptest.NewRequest("BREW", "/test-route", nil))
+		if got := httpRequestsTotal.Value("/test-route", "other", "404"); got != before+1 {
+			t.Errorf("Expected an unknown method to be counted as other; got %v -> %v", before, got)
+		}
+		if got := httpRequestsTotal.Value("/test-route", "BREW", "404"); got != 0 {
+			t.Errorf("Expected no series for the unknown method; got %v", got)
+		}
+	})
+}
+
+func TestMetricsHandler(t *testing.T) {
+	// Arrange
+	useStore(t, 0, Item{ID: 1, Name: "A"}, Item{ID: 2, Name: "B"})
+	eventPublisher = nil
+	rec := httptest.NewRecorder()
+
+	// Act
+	metricsHandler(rec)
+
+	// Assert
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Unexpected content type: %s", ct)
+	}
+	out := rec.Body.String()
+	for _, want := range []string{"items 2", "amqp_connection_up 0", "amqp_channel_up 0"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q; got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// openAPIDocument is the part of the OpenAPI document checked against the code
+type openAPIDocument struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Required   []string                   `json:"required"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPI(t *testing.T) openAPIDocument {
+	t.Helper()
+	var doc openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("Could not parse openapi.json: %v", err)
+	}
+	return doc
+}
+
+// jsonFields returns the JSON property names of struct type v
+func jsonFields(v any) []string {
+	var names []string
+	typ := reflect.TypeOf(v)
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	// Arrange
+	doc := loadOpenAPI(t)
+	useAuthenticator(t, AuthConfig{Enabled: true, BootstrapAPIKey: "admin-key"})
+	useStore(t, 0)
+	mux := newMux()
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+	t.Run("Version", func(t *testing.T) {
+		if !strings.HasPrefix(doc.OpenAPI, "3.1") {
+			t.Errorf("Expected OpenAPI 3.1; got %q", doc.OpenAPI)
+		}
+	})
+
+	t.Run("Paths", func(t *testing.T) {
+		var specPaths []string
+		for path := range doc.Paths {
+			specPaths = append(specPaths, path)
+		}
+		routes := mux.Patterns()
+		sort.Strings(specPaths)
+		sort.Strings(routes)
+		if !slices.Equal(specPaths, routes) {
+			t.Errorf("Expected spec paths %v to match routes %v", specPaths, routes)
+		}
+	})
+
+	for _, pattern := range mux.Patterns() {
+		t.Run("Methods"+pattern, func(t *testing.T) {
+			for _, method := range methods {
+				// Act
+				req := httptest.NewRequest(method, pattern, strings.NewReader("{"))
+				req.Header.Set(APIKeyHeader, "admin-key")
+				rr := httptest.NewRecorder()
+				mux.ServeHTTP(rr, req)
+
+				// Assert
+				_, documented := doc.Paths[pattern][strings.ToLower(method)]
+				if handled := rr.Code != http.StatusMethodNotAllowed; handled != documented {
+					t.Errorf("%s %s: expected documented=%v to match handled=%v (status %d)", method, pattern, documented, handled, rr.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPISchemasMatchTypes(t *testing.T) {
+	doc := loadOpenAPI(t)
+	types := map[string]any{
+		"Item":            Item{},
+		"ItemsSnapshot":   ItemsSnapshot{},
+		"SnapshotItem":    SnapshotItem{},
+		"Problem":         Problem{},
+		"APIKey":          apiKeyView{},
+		"AuditRecord":     AuditRecord{},
+		"HealthReport":    HealthReport{},
+		"ComponentStatus": ComponentStatus{},
+	}
+	for name, v := range types {
+		t.Run(name, func(t *testing.T) {
+			schema, ok := doc.Components.Schemas[name]
+			if !ok {
+				t.Fatalf("Expected schema %s in openapi.json", name)
+			}
+			var properties []string
+			for property := range schema.Properties {
+				properties = append(properties, property)
+			}
+			sort.Strings(properties)
+			if want := jsonFields(v); !slices.Equal(properties, want) {
+				t.Errorf("Expected properties %v; got %v", want, properties)
+			}
+			for _, required := range schema.Required {
+				if _, ok := schema.Properties[required]; !ok {
+					t.Errorf("Required property %q is not defined", required)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	t.Run("DocsLoadNothingRemote", func(t *testing.T) {
+		if strings.Contains(string(docsPage), "://") {
+			t.Errorf("Expected the docs page to load only embedded assets; got %s", docsPage)
+		}
+	})
+
+	tests := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{"/openapi.json", "application/json", `"openapi": "3.1.0"`},
+		{"/docs", "text/html; charset=utf-8", "docs/assets/swagger-ui-bundle.js"},
+		{"/docs/assets/swagger-ui-bundle.js", "text/javascript; charset=utf-8", "SwaggerUIBundle"},
+		{"/docs/assets/swagger-ui.css", "text/css; charset=utf-8", ".swagger-ui"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// Arrange
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			// Act
+			newMux().ServeHTTP(rr, req)
+
+			// Assert
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status OK; got %v", rr.Code)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Expected content type %q; got %q", tt.contentType, got)
+			}
+			if !strings.Contains(rr.Body.String(), tt.contains) {
+				t.Errorf("Expected body to contain %q", tt.contains)
+			}
+		})
+	}
+}
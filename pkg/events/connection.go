@@ -0,0 +1,151 @@
+package events
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Connection defaults shared by the server and consumers, so both connect
+// the same way when nothing is configured
+const (
+	DefaultURL       = "amqp://localhost:5672/"
+	DefaultHeartbeat = 10 * time.Second
+	DefaultLocale    = "en_US"
+)
+
+// ConnectionConfig configures how Dial connects to the broker
+type ConnectionConfig struct {
+	URL string
+	// Username, Password and PasswordFile override credentials in the URL
+	Username     string
+	Password     string
+	PasswordFile string
+	TLS          TLSConfig
+	// Heartbeat is the negotiated AMQP heartbeat interval
+	Heartbeat      time.Duration
+	Locale         string
+	ConnectionName string
+}
+
+// TLSConfig configures TLS for amqps:// URLs
+type TLSConfig struct {
+	// CAFile is the CA bundle the broker certificate is verified against;
+	// empty uses the system roots
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the host name verified in the broker certificate
+	ServerName string
+}
+
+// credentials returns the username and password configured separately from
+// the URL, reading the password from PasswordFile when set. ok is false
+// when no username is configured and the URL's credentials apply.
+func (cfg ConnectionConfig) credentials() (username, password string, ok bool, err error) {
+	if cfg.Username == "" {
+		return "", "", false, nil
+	}
+	password = cfg.Password
+	if cfg.PasswordFile != "" {
+		data, err := os.ReadFile(cfg.PasswordFile)
+		if err != nil {
+			return "", "", false, fmt.Errorf("failed to read broker password file: %w", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	}
+	return cfg.Username, password, true, nil
+}
+
+// tlsConfig builds the client TLS configuration for amqps URLs
+func (cfg ConnectionConfig) tlsConfig() (*tls.Config, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, errors.New("broker URL is not valid")
+	}
+	if u.Scheme != "amqps" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: cfg.TLS.ServerName}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = u.Hostname()
+	}
+	if cfg.TLS.CAFile != "" {
+		pem, err := os.ReadFile(cfg.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read broker CA bundle: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("broker CA bundle contains no certificates")
+		}
+	}
+	if cfg.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load broker client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// amqpConfig builds the connection settings used to dial the broker
+func (cfg ConnectionConfig) amqpConfig() (amqp.Config, error) {
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return amqp.Config{}, err
+	}
+	properties := amqp.NewConnectionProperties()
+	if cfg.ConnectionName != "" {
+		properties.SetClientConnectionName(cfg.ConnectionName)
+	}
+	c := amqp.Config{
+		Heartbeat:       cfg.Heartbeat,
+		Locale:          cfg.Locale,
+		Properties:      properties,
+		TLSClientConfig: tlsConfig,
+		Dial:            amqp.DefaultDial(dialTimeout),
+	}
+	username, password, ok, err := cfg.credentials()
+	if err != nil {
+		return amqp.Config{}, err
+	}
+	if ok {
+		c.SASL = []amqp.Authentication{&amqp.PlainAuth{Username: username, Password: password}}
+	}
+	return c, nil
+}
+
+// dialTimeout bounds the TCP connect to the broker
+const dialTimeout = 30 * time.Second
+
+// Dial connects to RabbitMQ with the configured TLS, credentials and
+// connection settings. Errors never include the broker's credentials.
+func Dial(cfg ConnectionConfig) (*amqp.Connection, error) {
+	c, err := cfg.amqpConfig()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := amqp.DialConfig(cfg.URL, c)
+	if err != nil {
+		return nil, errors.New(RedactCredentials(err.Error()))
+	}
+	return conn, nil
+}
+
+// credentialsInURL matches the user info of URLs embedded in text
+var credentialsInURL = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]*):[^@\s]*@`)
+
+// RedactCredentials hides passwords of any URLs found in s
+func RedactCredentials(s string) string {
+	return credentialsInURL.ReplaceAllString(s, "${1}:REDACTED@")
+}
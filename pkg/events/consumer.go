@@ -0,0 +1,307 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ConsumerOptions configures a Consumer
+type ConsumerOptions struct {
+	// Schemas decodes and upcasts deliveries; nil uses NewItemEventSchemas
+	Schemas *EventSchemaRegistry
+	// UnknownVersion is UnknownVersionReject (the default) or UnknownVersionPass
+	UnknownVersion string
+	// HandlerTimeout cancels the context of every handler call after the
+	// duration, and the delivery is requeued whatever the handler returns
+	// once its context has ended. Zero means no limit.
+	HandlerTimeout time.Duration
+	// HandlerGracePeriod is how long the consumer waits for a handler to
+	// return after its context ended, on timeout or Close. A handler still
+	// running then is abandoned: its delivery is requeued and the next one
+	// handled while it keeps running in the background. Zero uses
+	// DefaultHandlerGracePeriod.
+	HandlerGracePeriod time.Duration
+	Instrumentation    Instrumentation
+}
+
+// Message is a decoded delivery together with its metadata
+type Message struct {
+	// Event is the decoded event, upcast to the current schema version
+	Event ItemEvent
+	// SchemaVersion is the version the event was published with
+	SchemaVersion int
+	MessageID     string
+	CorrelationID string
+	RoutingKey    string
+	// Redelivered is set when the delivery was requeued or left
+	// unacknowledged before
+	Redelivered bool
+	Headers     amqp.Table
+	Timestamp   time.Time
+	// Body is the undecoded payload
+	Body []byte
+}
+
+// MessageHandler handles one delivery. ctx is cancelled when the consumer is
+// closed or the handler timeout expires.
+type MessageHandler func(ctx context.Context, msg *Message) error
+
+// EventHandler adapts a handler of events to a MessageHandler
+func EventHandler(handler func(ItemEvent) error) MessageHandler {
+	return func(_ context.Context, msg *Message) error {
+		return handler(msg.Event)
+	}
+}
+
+// ContextHandler adapts a handler of events taking a context, such as
+// Router.HandleEvent, to a MessageHandler
+func ContextHandler(handler func(context.Context, ItemEvent) error) MessageHandler {
+	return func(ctx context.Context, msg *Message) error {
+		return handler(ctx, msg.Event)
+	}
+}
+
+// Consumer handles consuming events from RabbitMQ
+type Consumer struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	queue   amqp.Queue
+	opts    ConsumerOptions
+	// ctx is cancelled by Close to stop running handlers
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewConsumer declares topology on conn and returns a consumer of its
+// queue. The consumer owns conn: it is closed by Close, or right away when
+// setup fails.
+func NewConsumer(conn *amqp.Connection, topology Topology, opts ConsumerOptions) (*Consumer, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	q, err := DeclareTopology(ch, topology)
+	if err != nil {
+		ch.Close()
+		conn.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Consumer{
+		conn:    conn,
+		channel: ch,
+		queue:   q,
+		opts:    opts,
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+// Consume starts consuming events from RabbitMQ
+func (c *Consumer) Consume(handler func(ItemEvent) error) error {
+	return c.ConsumeMessages(EventHandler(handler))
+}
+
+// ConsumeContext starts consuming events from RabbitMQ, passing handler the
+// context returned by the StartDelivery hook
+func (c *Consumer) ConsumeContext(handler func(context.Context, ItemEvent) error) error {
+	return c.ConsumeMessages(ContextHandler(handler))
+}
+
+// ConsumeMessages starts consuming events from RabbitMQ, passing handler
+// every decoded delivery with its metadata
+func (c *Consumer) ConsumeMessages(handler MessageHandler) error {
+	if c.channel == nil {
+		return fmt.Errorf("channel is not initialized")
+	}
+
+	msgs, err := c.channel.Consume(
+		c.queue.Name, // queue
+		"",           // consumer
+		false,        // auto-ack
+		false,        // exclusive
+		false,        // no-local
+		false,        // no-wait
+		nil,          // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register consumer: %w", err)
+	}
+
+	go func() {
+		for d := range msgs {
+			c.handleDelivery(handler, d)
+		}
+	}()
+
+	slog.Info("consumer started, waiting for events", "queue", c.queue.Name)
+	return nil
+}
+
+// handleDelivery decodes one delivery, runs handler and acks, requeues or
+// rejects it depending on the outcome. Handler errors wrapping ErrDeadLetter
+// reject without requeue, unless the handler timed out, and so does a panic
+// on a redelivery, so a message that always panics is retried only once.
+func (c *Consumer) handleDelivery(handler MessageHandler, d amqp.Delivery) {
+	ctx := context.Background()
+	finish := func(DeliveryResult) {}
+	if c.opts.Instrumentation.StartDelivery != nil {
+		ctx, finish = c.opts.Instrumentation.StartDelivery(d)
+	}
+
+	schemas := c.opts.Schemas
+	if schemas == nil {
+		schemas = defaultSchemas
+	}
+	event, version, err := schemas.Decode(d.Body)
+	if errors.Is(err, ErrUnknownSchemaVersion) && c.opts.UnknownVersion == UnknownVersionPass {
+		slog.WarnContext(ctx, "passing event with unknown schema version", "schema_version", version)
+		event = ItemEvent{}
+		err = json.Unmarshal(d.Body, &event)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to decode event", "schema_version", version, "error", err)
+		d.Nack(false, false) // reject message
+		finish(DeliveryResult{Outcome: OutcomeNacked, SchemaVersion: version, Err: err})
+		return
+	}
+	ctx = withRawEvent(ctx, d.Body)
+
+	msg := &Message{
+		Event:         event,
+		SchemaVersion: version,
+		MessageID:     d.MessageId,
+		CorrelationID: d.CorrelationId,
+		RoutingKey:    d.RoutingKey,
+		Redelivered:   d.Redelivered,
+		Headers:       d.Headers,
+		Timestamp:     d.Timestamp,
+		Body:          d.Body,
+	}
+	if err := c.runHandler(ctx, handler, msg); err != nil {
+		slog.ErrorContext(ctx, "failed to handle event", "event_type", event.Type, "item_id", event.Item.ID, "redelivered", d.Redelivered, "error", err)
+		panicked := errors.Is(err, ErrHandlerPanic)
+		if (errors.Is(err, ErrDeadLetter) && !errors.Is(err, context.DeadlineExceeded)) || (panicked && d.Redelivered) {
+			d.Nack(false, false) // reject message
+			finish(DeliveryResult{Outcome: OutcomeNacked, Event: &event, SchemaVersion: version, Panicked: panicked, Err: err})
+			return
+		}
+		d.Nack(false, true) // requeue message
+		finish(DeliveryResult{Outcome: OutcomeRequeued, Event: &event, SchemaVersion: version, Panicked: panicked, Err: err})
+		return
+	}
+	d.Ack(false) // acknowledge message
+	finish(DeliveryResult{Outcome: OutcomeProcessed, Event: &event, SchemaVersion: version})
+	slog.DebugContext(ctx, "processed event", "event_type", event.Type, "item_id", event.Item.ID)
+}
+
+// ErrHandlerPanic is wrapped by the error a panicking handler is turned into
+var ErrHandlerPanic = errors.New("event handler panicked")
+
+// ErrHandlerAbandoned is wrapped by the error of a handler that did not
+// return within the grace period after its context ended
+var ErrHandlerAbandoned = errors.New("event handler abandoned")
+
+// DefaultHandlerGracePeriod is the HandlerGracePeriod used when none is set
+const DefaultHandlerGracePeriod = 5 * time.Second
+
+// runHandler calls handler with a context cancelled on Close and after the
+// handler timeout, and waits for it to return so that deliveries are handled
+// one at a time. Once the context has ended the result is the context's
+// error, so the delivery is requeued, and a handler that does not return
+// within the grace period is abandoned. A panic is recovered and returned
+// as an error wrapping ErrHandlerPanic.
+func (c *Consumer) runHandler(ctx context.Context, handler MessageHandler, msg *Message) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if c.ctx != nil {
+		stop := context.AfterFunc(c.ctx, cancel)
+		defer stop()
+	}
+	if c.opts.HandlerTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.opts.HandlerTimeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- recoverHandler(ctx, msg.Event, p)
+			}
+		}()
+		done <- handler(ctx, msg)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		grace := c.opts.HandlerGracePeriod
+		if grace <= 0 {
+			grace = DefaultHandlerGracePeriod
+		}
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case err = <-done:
+		case <-timer.C:
+			slog.ErrorContext(ctx, "abandoned event handler that ignored its context", "event_type", msg.Event.Type, "item_id", msg.Event.Item.ID, "grace_period", grace)
+			return fmt.Errorf("%w after %v: %w", ErrHandlerAbandoned, grace, ctx.Err())
+		}
+	}
+	if ctx.Err() != nil && !errors.Is(err, ctx.Err()) {
+		slog.WarnContext(ctx, "event handler returned after its context ended", "event_type", msg.Event.Type, "item_id", msg.Event.Item.ID, "error", err)
+		return errors.Join(ctx.Err(), err)
+	}
+	return err
+}
+
+// recoverHandler logs the panic p of the handler of event with its stack
+// trace and returns it as an error. A panic with an error value wraps it, so
+// panicking with ErrDeadLetter rejects the delivery.
+func recoverHandler(ctx context.Context, event ItemEvent, p any) error {
+	slog.ErrorContext(ctx, "event handler panicked", "event_type", event.Type, "item_id", event.Item.ID, "panic", p, "stack", string(debug.Stack()))
+	if err, ok := p.(error); ok {
+		return fmt.Errorf("%w: %w", ErrHandlerPanic, err)
+	}
+	return fmt.Errorf("%w: %v", ErrHandlerPanic, p)
+}
+
+// DeliveryRequestID returns the originating request ID of a delivery
+func DeliveryRequestID(d amqp.Delivery) string {
+	if d.CorrelationId != "" {
+		return d.CorrelationId
+	}
+	id, _ := d.Headers[RequestIDHeader].(string)
+	return id
+}
+
+// Close cancels the context of running handlers and closes the connection
+// and channel
+func (c *Consumer) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	if c.channel != nil {
+		if err := c.channel.Close(); err != nil {
+			return err
+		}
+	}
+	if c.conn != nil {
+		if err := c.conn.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
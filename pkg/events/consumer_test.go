@@ -0,0 +1,267 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ctxKey tags contexts created by test hooks
+type ctxKey struct{}
+
+func TestConsumerInstrumentation(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		handlerErr  error
+		wantOutcome string
+		wantVersion int
+	}{
+		{"Processed", `{"schemaVersion":2,"type":"item.created","tenantId":"acme","item":{"id":1,"name":"Anvil"}}`, nil, OutcomeProcessed, 2},
+		{"ProcessedAfterUpcast", `{"type":"item.created","item":{"id":1,"name":"Anvil"}}`, nil, OutcomeProcessed, 1},
+		{"Requeued", `{"schemaVersion":2,"type":"item.created","item":{"id":1}}`, errors.New("busy"), OutcomeRequeued, 2},
+		{"Nacked", `not json`, nil, OutcomeNacked, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var result DeliveryResult
+			var handlerValue any
+			consumer := &Consumer{opts: ConsumerOptions{Instrumentation: Instrumentation{
+				StartDelivery: func(d amqp.Delivery) (context.Context, func(DeliveryResult)) {
+					return context.WithValue(context.Background(), ctxKey{}, "from hook"), func(r DeliveryResult) { result = r }
+				},
+			}}}
+
+			// Act
+			consumer.handleDelivery(ContextHandler(func(ctx context.Context, event ItemEvent) error {
+				handlerValue = ctx.Value(ctxKey{})
+				return tt.handlerErr
+			}), amqp.Delivery{Acknowledger: &fakeAcknowledger{}, Body: []byte(tt.body)})
+
+			// Assert
+			if result.Outcome != tt.wantOutcome || result.SchemaVersion != tt.wantVersion {
+				t.Errorf("Expected outcome %s for version %d; got %+v", tt.wantOutcome, tt.wantVersion, result)
+			}
+			if tt.wantOutcome == OutcomeNacked {
+				if result.Event != nil || result.Err == nil {
+					t.Errorf("Expected a decode error without event; got %+v", result)
+				}
+				return
+			}
+			if result.Event == nil || result.Event.Item.ID != 1 {
+				t.Errorf("Expected the decoded event; got %+v", result.Event)
+			}
+			if handlerValue != "from hook" {
+				t.Error("Expected the handler to receive the hook's context")
+			}
+		})
+	}
+}
+
+func TestConsumerMessage(t *testing.T) {
+	// Arrange
+	var got *Message
+	d := amqp.Delivery{
+		Acknowledger:  &fakeAcknowledger{},
+		MessageId:     "msg-1",
+		CorrelationId: "req-1",
+		RoutingKey:    "acme.item.created",
+		Redelivered:   true,
+		Headers:       amqp.Table{RequestIDHeader: "req-1"},
+		Body:          []byte(`{"type":"item.created","item":{"id":1,"name":"Anvil"}}`),
+	}
+
+	// Act
+	(&Consumer{}).handleDelivery(func(_ context.Context, msg *Message) error {
+		got = msg
+		return nil
+	}, d)
+
+	// Assert
+	if got == nil {
+		t.Fatal("Expected the handler to be called")
+	}
+	if got.MessageID != "msg-1" || got.CorrelationID != "req-1" || got.RoutingKey != "acme.item.created" || !got.Redelivered {
+		t.Errorf("Unexpected delivery metadata: %+v", got)
+	}
+	if got.Headers[RequestIDHeader] != "req-1" || string(got.Body) != string(d.Body) {
+		t.Errorf("Expected headers and body of the delivery; got %v %s", got.Headers, got.Body)
+	}
+	if got.Event.Item.Name != "Anvil" || got.Event.TenantID != DefaultTenant || got.SchemaVersion != 1 {
+		t.Errorf("Expected the upcast event; got %+v (version %d)", got.Event, got.SchemaVersion)
+	}
+}
+
+func TestConsumerHandlerTimeout(t *testing.T) {
+	body := []byte(`{"schemaVersion":2,"type":"item.created","item":{"id":1}}`)
+	consumer := &Consumer{opts: ConsumerOptions{HandlerTimeout: 10 * time.Millisecond, HandlerGracePeriod: time.Second}}
+
+	tests := []struct {
+		name        string
+		handler     MessageHandler
+		wantAcked   bool
+		wantRequeue bool
+	}{
+		{"ReturnsContextError", func(ctx context.Context, _ *Message) error {
+			<-ctx.Done()
+			return fmt.Errorf("%w: gave up: %w", ErrDeadLetter, ctx.Err())
+		}, false, true},
+		{"SucceedsAfterDeadline", func(context.Context, *Message) error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}, false, true},
+		{"SucceedsInTime", func(context.Context, *Message) error {
+			return nil
+		}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			ack := &fakeAcknowledger{}
+
+			// Act
+			consumer.handleDelivery(tt.handler, amqp.Delivery{Acknowledger: ack, Body: body})
+
+			// Assert
+			if ack.acked != tt.wantAcked || ack.requeue != tt.wantRequeue {
+				t.Errorf("Expected acked %v and requeue %v; got %+v", tt.wantAcked, tt.wantRequeue, ack)
+			}
+		})
+	}
+
+	t.Run("WaitsForHandler", func(t *testing.T) {
+		// Arrange
+		var running, overlapped atomic.Bool
+		handler := func(context.Context, *Message) error {
+			if running.Swap(true) {
+				overlapped.Store(true)
+			}
+			time.Sleep(30 * time.Millisecond)
+			running.Store(false)
+			return nil
+		}
+
+		// Act
+		for range 3 {
+			consumer.handleDelivery(handler, amqp.Delivery{Acknowledger: &fakeAcknowledger{}, Body: body})
+		}
+
+		// Assert
+		if running.Load() || overlapped.Load() {
+			t.Error("Expected each handler to return before the next delivery is handled")
+		}
+	})
+
+	t.Run("AbandonsHungHandler", func(t *testing.T) {
+		// Arrange
+		consumer := &Consumer{opts: ConsumerOptions{HandlerTimeout: 10 * time.Millisecond, HandlerGracePeriod: 20 * time.Millisecond}}
+		ack := &fakeAcknowledger{}
+		release := make(chan struct{})
+		defer close(release)
+		var result DeliveryResult
+		consumer.opts.Instrumentation.StartDelivery = func(amqp.Delivery) (context.Context, func(DeliveryResult)) {
+			return context.Background(), func(r DeliveryResult) { result = r }
+		}
+		start := time.Now()
+
+		// Act
+		consumer.handleDelivery(func(context.Context, *Message) error {
+			<-release
+			return nil
+		}, amqp.Delivery{Acknowledger: ack, Body: body})
+
+		// Assert
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected the handler to be abandoned after the grace period; took %v", elapsed)
+		}
+		if ack.acked || !ack.requeue {
+			t.Errorf("Expected the delivery to be requeued; got %+v", ack)
+		}
+		if !errors.Is(result.Err, ErrHandlerAbandoned) || !errors.Is(result.Err, context.DeadlineExceeded) {
+			t.Errorf("Expected an abandoned handler error; got %v", result.Err)
+		}
+	})
+}
+
+func TestConsumerCloseCancelsHandlers(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer := &Consumer{ctx: ctx, cancel: cancel}
+	started := make(chan struct{})
+	ack := &fakeAcknowledger{}
+	done := make(chan struct{})
+
+	// Act
+	go func() {
+		defer close(done)
+		consumer.handleDelivery(func(ctx context.Context, _ *Message) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}, amqp.Delivery{Acknowledger: ack, Body: []byte(`{"schemaVersion":2,"type":"item.created"}`)})
+	}()
+	<-started
+	consumer.Close()
+
+	// Assert
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Close to cancel the running handler")
+	}
+	if !ack.nacked || !ack.requeue {
+		t.Errorf("Expected delivery to be requeued; got %+v", ack)
+	}
+}
+
+func TestConsumerRecoversHandlerPanics(t *testing.T) {
+	tests := []struct {
+		name        string
+		panicValue  any
+		redelivered bool
+		wantRequeue bool
+	}{
+		{"Requeued", "boom", false, true},
+		{"DeadLettered", fmt.Errorf("corrupt item: %w", ErrDeadLetter), false, false},
+		{"DeadLetteredOnRedelivery", "boom", true, false},
+	}
+	body := []byte(`{"schemaVersion":2,"type":"item.created","item":{"id":1}}`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var result DeliveryResult
+			consumer := &Consumer{opts: ConsumerOptions{Instrumentation: Instrumentation{
+				StartDelivery: func(amqp.Delivery) (context.Context, func(DeliveryResult)) {
+					return context.Background(), func(r DeliveryResult) { result = r }
+				},
+			}}}
+			ack := &fakeAcknowledger{}
+
+			// Act
+			consumer.handleDelivery(func(context.Context, *Message) error {
+				panic(tt.panicValue)
+			}, amqp.Delivery{Acknowledger: ack, Body: body, Redelivered: tt.redelivered})
+
+			// Assert
+			if !ack.nacked || ack.requeue != tt.wantRequeue {
+				t.Errorf("Expected nack with requeue %v; got %+v", tt.wantRequeue, ack)
+			}
+			if !result.Panicked || !errors.Is(result.Err, ErrHandlerPanic) {
+				t.Errorf("Expected the panic to be reported; got %+v", result)
+			}
+
+			// The next delivery is handled as usual
+			next := &fakeAcknowledger{}
+			consumer.handleDelivery(EventHandler(func(ItemEvent) error { return nil }), amqp.Delivery{Acknowledger: next, Body: body})
+			if !next.acked || result.Panicked {
+				t.Errorf("Expected the next delivery to be processed; got %+v", next)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Package events defines the item events published by Go-server-crud and
+// the RabbitMQ publisher and consumer shared by the server and its consumers.
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// EventType represents the type of event
+type EventType string
+
+const (
+	EventItemCreated EventType = "item.created"
+	EventItemUpdated EventType = "item.updated"
+	EventItemDeleted EventType = "item.deleted"
+	// EventItemRestored is published when a deleted item is taken back out
+	// of the trash
+	EventItemRestored EventType = "item.restored"
+	// EventItemPurged is published when a deleted item is removed for good
+	EventItemPurged EventType = "item.purged"
+)
+
+// EventTypes returns every EventType published by the server
+func EventTypes() []EventType {
+	return []EventType{EventItemCreated, EventItemUpdated, EventItemDeleted, EventItemRestored, EventItemPurged}
+}
+
+// DefaultTenant owns items of requests and events that name no tenant
+const DefaultTenant = "default"
+
+// AMQP message headers set on published events
+const (
+	// RequestIDHeader carries the originating HTTP request ID
+	RequestIDHeader = "x-request-id"
+	// TraceparentHeader carries the W3C trace context of the publish
+	TraceparentHeader = "traceparent"
+)
+
+// Item is the item carried by an event. Its JSON encoding matches the
+// server's items API.
+type Item struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// DeletedAt is set while the item is in the trash
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// ItemEvent represents an event related to an item
+type ItemEvent struct {
+	// SchemaVersion is the payload version, see EventSchemaVersion
+	SchemaVersion int       `json:"schemaVersion"`
+	Type          EventType `json:"type"`
+	TenantID      string    `json:"tenantId"`
+	Item          Item      `json:"item"`
+	// ItemVersion counts the changes to the item, starting at 1 when it is
+	// created. Consumers can ignore events older than the version they have
+	// applied. Zero when the publisher does not number changes.
+	ItemVersion int `json:"itemVersion,omitempty"`
+	// Sequence numbers the publisher's changes to the tenant in the order
+	// they were applied; a snapshot of the tenant taken at sequence n
+	// reflects exactly its events up to n. Zero when the publisher does not
+	// number changes.
+	Sequence int64 `json:"sequence,omitempty"`
+	// Epoch identifies the publisher's store. Versions and sequences start
+	// over when it changes, so a consumer seeing a newer epoch discards
+	// what it derived from the older one. Zero when the publisher does not
+	// number changes.
+	Epoch     int64     `json:"epoch,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Actor is the authenticated subject that caused the event
+	Actor string `json:"actor,omitempty"`
+}
+
+// DefaultQueue is the queue events are published to and consumed from when
+// none is configured
+const DefaultQueue = "item_events"
+
+// Topology names the queue events are delivered to and, optionally, the
+// topic exchange they are published to and the dead-letter exchange
+// rejected events are moved to
+type Topology struct {
+	// Exchange is empty to publish to Queue on the default exchange
+	Exchange string
+	Queue    string
+	// DeadLetterExchange receives the events consumers reject without
+	// requeue; empty discards them
+	DeadLetterExchange string
+	// DeadLetterQueue collects the dead-lettered events; empty uses Queue
+	// with a ".dead-letter" suffix
+	DeadLetterQueue string
+}
+
+// deadLetterQueue returns the name of the queue bound to the dead-letter
+// exchange
+func (t Topology) deadLetterQueue() string {
+	if t.DeadLetterQueue != "" {
+		return t.DeadLetterQueue
+	}
+	return t.Queue + ".dead-letter"
+}
+
+// queueArguments returns the arguments of the event queue. RabbitMQ refuses
+// to redeclare a queue with different arguments, so publishers and
+// consumers of a queue must agree on the dead-letter exchange.
+func (t Topology) queueArguments() amqp.Table {
+	if t.DeadLetterExchange == "" {
+		return nil
+	}
+	return amqp.Table{"x-dead-letter-exchange": t.DeadLetterExchange}
+}
+
+// DeclareTopology declares the event queue and, when an exchange is
+// configured, a durable topic exchange bound to it for every tenant and
+// event type. With a dead-letter exchange, it also declares that exchange
+// as a durable fanout and a queue collecting everything dead-lettered.
+func DeclareTopology(ch *amqp.Channel, t Topology) (amqp.Queue, error) {
+	if t.DeadLetterExchange != "" {
+		if err := declareDeadLetter(ch, t); err != nil {
+			return amqp.Queue{}, err
+		}
+	}
+	q, err := ch.QueueDeclare(
+		t.Queue,            // name
+		true,               // durable
+		false,              // delete when unused
+		false,              // exclusive
+		false,              // no-wait
+		t.queueArguments(), // arguments
+	)
+	if err != nil {
+		return q, fmt.Errorf("failed to declare queue: %w", err)
+	}
+	if t.Exchange == "" {
+		return q, nil
+	}
+
+	err = ch.ExchangeDeclare(
+		t.Exchange, // name
+		"topic",    // kind
+		true,       // durable
+		false,      // auto-deleted
+		false,      // internal
+		false,      // no-wait
+		nil,        // arguments
+	)
+	if err != nil {
+		return q, fmt.Errorf("failed to declare exchange: %w", err)
+	}
+	if err := ch.QueueBind(q.Name, "*.item.*", t.Exchange, false, nil); err != nil {
+		return q, fmt.Errorf("failed to bind queue: %w", err)
+	}
+	return q, nil
+}
+
+// declareDeadLetter declares the dead-letter exchange of t and the queue
+// bound to it
+func declareDeadLetter(ch *amqp.Channel, t Topology) error {
+	err := ch.ExchangeDeclare(
+		t.DeadLetterExchange, // name
+		"fanout",             // kind
+		true,                 // durable
+		false,                // auto-deleted
+		false,                // internal
+		false,                // no-wait
+		nil,                  // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+	dlq, err := ch.QueueDeclare(
+		t.deadLetterQueue(), // name
+		true,                // durable
+		false,               // delete when unused
+		false,               // exclusive
+		false,               // no-wait
+		nil,                 // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
+	if err := ch.QueueBind(dlq.Name, "", t.DeadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %w", err)
+	}
+	return nil
+}
+
+// Delivery outcomes reported to Instrumentation
+const (
+	OutcomeProcessed = "processed"
+	OutcomeNacked    = "nacked"
+	OutcomeRequeued  = "requeued"
+)
+
+// DeliveryResult describes how a delivery was settled
+type DeliveryResult struct {
+	// Outcome is OutcomeProcessed, OutcomeNacked or OutcomeRequeued
+	Outcome string
+	// Event is the decoded event, nil when decoding failed
+	Event *ItemEvent
+	// SchemaVersion is the version the event was published with
+	SchemaVersion int
+	// Panicked is set when the handler panicked
+	Panicked bool
+	Err      error
+}
+
+// Instrumentation observes publishing and consuming, e.g. for tracing and
+// metrics. Nil hooks are skipped.
+type Instrumentation struct {
+	// StartPublish runs before event is published. It returns the context
+	// of the publish, headers added to the message and a function called
+	// with the publish error.
+	StartPublish func(ctx context.Context, event ItemEvent, exchange, routingKey string) (context.Context, amqp.Table, func(error))
+	// StartDelivery runs when a delivery arrives. It returns the context
+	// passed to the handler and a function called once the delivery is
+	// settled.
+	StartDelivery func(d amqp.Delivery) (context.Context, func(DeliveryResult))
+}
@@ -0,0 +1,232 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DeliverySpan records the processing of one delivery as a child of the
+// publisher's span, so it joins the trace of the originating request
+type DeliverySpan struct {
+	Name         string    `json:"name"`
+	TraceID      string    `json:"traceId"`
+	SpanID       string    `json:"spanId"`
+	ParentSpanID string    `json:"parentSpanId,omitempty"`
+	Sampled      bool      `json:"sampled"`
+	RequestID    string    `json:"requestId,omitempty"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Outcome      string    `json:"outcome,omitempty"`
+	EventType    EventType `json:"eventType,omitempty"`
+	ItemID       int       `json:"itemId,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Traceparent is the W3C traceparent of the span, for propagating the trace
+// from a handler
+func (s DeliverySpan) Traceparent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID + "-" + s.SpanID + "-" + flags
+}
+
+// deliverySpanKey and requestIDKey tag the handler context values set by
+// DeliveryInstrumentation
+type (
+	deliverySpanKey struct{}
+	requestIDKey    struct{}
+)
+
+// SpanFromContext returns the delivery span of a handler context created by
+// DeliveryInstrumentation
+func SpanFromContext(ctx context.Context) (DeliverySpan, bool) {
+	span, ok := ctx.Value(deliverySpanKey{}).(DeliverySpan)
+	return span, ok
+}
+
+// RequestIDFromContext returns the originating request ID of a handler
+// context created by DeliveryInstrumentation
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// parseTraceparent returns the trace ID, parent span ID and sampled flag of
+// a version 00 W3C traceparent value
+func parseTraceparent(value string) (traceID, spanID string, sampled, ok bool) {
+	if len(value) != 55 || value[:3] != "00-" || value[35] != '-' || value[52] != '-' {
+		return "", "", false, false
+	}
+	traceID, spanID = value[3:35], value[36:52]
+	for _, id := range []string{traceID, spanID} {
+		b, err := hex.DecodeString(id)
+		if err != nil || !slices.ContainsFunc(b, func(c byte) bool { return c != 0 }) {
+			return "", "", false, false
+		}
+	}
+	flags, err := strconv.ParseUint(value[53:], 16, 8)
+	if err != nil {
+		return "", "", false, false
+	}
+	return traceID, spanID, flags&1 == 1, true
+}
+
+// randomID returns n random bytes hex encoded
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// DeliveryInstrumentation returns Instrumentation for consumers that
+// continues the publisher's trace and request ID in the handler's context,
+// counts every settled delivery in metrics and hands the finished span to
+// export. metrics and export may be nil.
+func DeliveryInstrumentation(metrics *ConsumerMetrics, export func(DeliverySpan)) Instrumentation {
+	return Instrumentation{
+		StartDelivery: func(d amqp.Delivery) (context.Context, func(DeliveryResult)) {
+			span := DeliverySpan{
+				Name:      "process " + d.RoutingKey,
+				SpanID:    randomID(8),
+				RequestID: DeliveryRequestID(d),
+				Start:     time.Now(),
+			}
+			tp, _ := d.Headers[TraceparentHeader].(string)
+			if traceID, parentID, sampled, ok := parseTraceparent(tp); ok {
+				span.TraceID, span.ParentSpanID, span.Sampled = traceID, parentID, sampled
+			} else {
+				span.TraceID, span.Sampled = randomID(16), true
+			}
+			ctx := context.WithValue(context.Background(), deliverySpanKey{}, span)
+			ctx = context.WithValue(ctx, requestIDKey{}, span.RequestID)
+			return ctx, func(r DeliveryResult) {
+				span.End = time.Now()
+				span.Outcome = r.Outcome
+				if r.Event != nil {
+					span.EventType = r.Event.Type
+					span.ItemID = r.Event.Item.ID
+				}
+				if r.Err != nil {
+					span.Error = r.Err.Error()
+				}
+				if metrics != nil {
+					metrics.observe(r)
+				}
+				if export != nil && span.Sampled {
+					export(span)
+				}
+			}
+		},
+	}
+}
+
+// ConsumerMetrics counts the deliveries settled by consumers using
+// DeliveryInstrumentation under the metric names the server uses
+type ConsumerMetrics struct {
+	mu       sync.Mutex
+	consumed map[string]int64
+	upcast   map[int]int64
+	panics   map[EventType]int64
+}
+
+// NewConsumerMetrics returns empty consumer metrics
+func NewConsumerMetrics() *ConsumerMetrics {
+	return &ConsumerMetrics{
+		consumed: map[string]int64{},
+		upcast:   map[int]int64{},
+		panics:   map[EventType]int64{},
+	}
+}
+
+// observe counts a settled delivery
+func (m *ConsumerMetrics) observe(r DeliveryResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.consumed[r.Outcome]++
+	if r.Event != nil {
+		if r.SchemaVersion < EventSchemaVersion {
+			m.upcast[r.SchemaVersion]++
+		}
+		if r.Panicked {
+			m.panics[r.Event.Type]++
+		}
+	}
+}
+
+// Consumed returns the deliveries settled with outcome
+func (m *ConsumerMetrics) Consumed(outcome string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.consumed[outcome]
+}
+
+// Upcast returns the events upcast from schema version
+func (m *ConsumerMetrics) Upcast(version int) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.upcast[version]
+}
+
+// Panics returns the handlers of eventType that panicked
+func (m *ConsumerMetrics) Panics(eventType EventType) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.panics[eventType]
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format
+func (m *ConsumerMetrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var err error
+	write := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	write("# HELP events_consumed_total Events consumed from RabbitMQ by outcome (processed, nacked, requeued).\n# TYPE events_consumed_total counter\n")
+	for _, outcome := range sortedKeys(m.consumed) {
+		write("events_consumed_total{outcome=%q} %d\n", outcome, m.consumed[outcome])
+	}
+	write("# HELP events_upcast_total Consumed events upcast from an older schema version by that version.\n# TYPE events_upcast_total counter\n")
+	for _, version := range sortedKeys(m.upcast) {
+		write("events_upcast_total{version=\"%d\"} %d\n", version, m.upcast[version])
+	}
+	write("# HELP event_handler_panics_total Event handlers that panicked by event type.\n# TYPE event_handler_panics_total counter\n")
+	for _, eventType := range sortedKeys(m.panics) {
+		write("event_handler_panics_total{event_type=%q} %d\n", eventType, m.panics[eventType])
+	}
+	return err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format
+func (m *ConsumerMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WritePrometheus(w)
+}
+
+// sortedKeys returns the keys of m in ascending order
+func sortedKeys[K interface{ ~int | ~string }, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
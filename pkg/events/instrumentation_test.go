@@ -0,0 +1,102 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestDeliveryInstrumentationContinuesTrace(t *testing.T) {
+	// Arrange
+	var spans []DeliverySpan
+	var handlerSpan DeliverySpan
+	var handlerRequestID string
+	consumer := &Consumer{opts: ConsumerOptions{Instrumentation: DeliveryInstrumentation(nil, func(s DeliverySpan) { spans = append(spans, s) })}}
+	d := amqp.Delivery{
+		Acknowledger:  &fakeAcknowledger{},
+		RoutingKey:    string(EventItemCreated),
+		CorrelationId: "req-9",
+		Headers:       amqp.Table{TraceparentHeader: testTraceparent},
+		Body:          []byte(`{"schemaVersion":2,"type":"item.created","item":{"id":7,"name":"Anvil"}}`),
+	}
+
+	// Act
+	consumer.handleDelivery(ContextHandler(func(ctx context.Context, event ItemEvent) error {
+		handlerSpan, _ = SpanFromContext(ctx)
+		handlerRequestID = RequestIDFromContext(ctx)
+		return nil
+	}), d)
+
+	// Assert
+	if handlerRequestID != "req-9" {
+		t.Errorf("Expected request ID req-9 in handler context; got %q", handlerRequestID)
+	}
+	if handlerSpan.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || handlerSpan.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected handler context to continue the publisher's trace; got %+v", handlerSpan)
+	}
+	if len(spans) != 1 || spans[0].SpanID != handlerSpan.SpanID || spans[0].Outcome != OutcomeProcessed || spans[0].ItemID != 7 {
+		t.Errorf("Expected one exported process span; got %+v", spans)
+	}
+	if tp := handlerSpan.Traceparent(); !strings.HasPrefix(tp, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || tp == testTraceparent {
+		t.Errorf("Expected a child traceparent; got %q", tp)
+	}
+}
+
+func TestDeliveryInstrumentationStartsTrace(t *testing.T) {
+	for _, tp := range []any{nil, "garbage", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		ctx, finish := DeliveryInstrumentation(nil, nil).StartDelivery(amqp.Delivery{Headers: amqp.Table{TraceparentHeader: tp}})
+		finish(DeliveryResult{Outcome: OutcomeProcessed})
+		span, ok := SpanFromContext(ctx)
+		if !ok || len(span.TraceID) != 32 || span.ParentSpanID != "" {
+			t.Errorf("Expected a new root trace for traceparent %v; got %+v", tp, span)
+		}
+	}
+}
+
+func TestConsumerMetrics(t *testing.T) {
+	// Arrange
+	metrics := NewConsumerMetrics()
+	instrumentation := DeliveryInstrumentation(metrics, nil)
+	results := []DeliveryResult{
+		{Outcome: OutcomeProcessed, Event: &ItemEvent{Type: EventItemCreated}, SchemaVersion: EventSchemaVersion},
+		{Outcome: OutcomeProcessed, Event: &ItemEvent{Type: EventItemCreated}, SchemaVersion: 1},
+		{Outcome: OutcomeRequeued, Event: &ItemEvent{Type: EventItemUpdated}, SchemaVersion: EventSchemaVersion, Panicked: true, Err: ErrHandlerPanic},
+		{Outcome: OutcomeNacked, Err: errors.New("not json")},
+	}
+
+	// Act
+	for _, r := range results {
+		_, finish := instrumentation.StartDelivery(amqp.Delivery{})
+		finish(r)
+	}
+
+	// Assert
+	if metrics.Consumed(OutcomeProcessed) != 2 || metrics.Consumed(OutcomeRequeued) != 1 || metrics.Consumed(OutcomeNacked) != 1 {
+		t.Errorf("Unexpected consumed counts: %+v", metrics.consumed)
+	}
+	if metrics.Upcast(1) != 1 {
+		t.Errorf("Expected one upcast from version 1; got %d", metrics.Upcast(1))
+	}
+	if metrics.Panics(EventItemUpdated) != 1 || metrics.Panics(EventItemCreated) != 0 {
+		t.Errorf("Unexpected panic counts: %+v", metrics.panics)
+	}
+	var buf bytes.Buffer
+	if err := metrics.WritePrometheus(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, line := range []string{
+		`events_consumed_total{outcome="processed"} 2`,
+		`events_upcast_total{version="1"} 1`,
+		`event_handler_panics_total{event_type="item.updated"} 1`,
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Expected %q in:\n%s", line, buf.String())
+		}
+	}
+}
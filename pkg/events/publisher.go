@@ -0,0 +1,265 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Publisher handles publishing events to RabbitMQ
+type Publisher struct {
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+	queue    amqp.Queue
+	exchange string
+	instr    Instrumentation
+
+	// mu guards the fields below. inflight counts running publishes, and
+	// drained is closed when the last of them finishes after Flush.
+	mu       sync.Mutex
+	closed   bool
+	inflight int
+	drained  chan struct{}
+	// unconfirmed holds the publishes the broker has not confirmed yet
+	unconfirmed map[uint64]*amqp.DeferredConfirmation
+}
+
+// NewPublisher declares topology on conn and returns a publisher for it.
+// The publisher owns conn: it is closed by Close, or right away when setup
+// fails.
+func NewPublisher(conn *amqp.Connection, topology Topology, instr Instrumentation) (*Publisher, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	q, err := DeclareTopology(ch, topology)
+	if err != nil {
+		ch.Close()
+		conn.Close()
+		return nil, err
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	return &Publisher{
+		conn:        conn,
+		channel:     ch,
+		queue:       q,
+		exchange:    topology.Exchange,
+		instr:       instr,
+		unconfirmed: map[uint64]*amqp.DeferredConfirmation{},
+	}, nil
+}
+
+// Publish publishes an event to RabbitMQ
+func (p *Publisher) Publish(event ItemEvent) error {
+	return p.PublishContext(context.Background(), event)
+}
+
+// PublishContext publishes an event to RabbitMQ with the headers returned
+// by the StartPublish hook for ctx. It does not wait for the broker to
+// confirm the event; Flush does.
+func (p *Publisher) PublishContext(ctx context.Context, event ItemEvent) error {
+	if err := p.begin(); err != nil {
+		return err
+	}
+	defer p.end()
+	if p.channel == nil {
+		return fmt.Errorf("channel is not initialized")
+	}
+
+	routingKey := p.routingKey(event)
+	var headers amqp.Table
+	finish := func(error) {}
+	if p.instr.StartPublish != nil {
+		ctx, headers, finish = p.instr.StartPublish(ctx, event, p.exchange, routingKey)
+	}
+
+	msg, err := NewPublishing(event, headers)
+	if err != nil {
+		finish(err)
+		return err
+	}
+
+	confirm, err := p.channel.PublishWithDeferredConfirmWithContext(
+		ctx,
+		p.exchange, // exchange
+		routingKey, // routing key
+		false,      // mandatory
+		false,      // immediate
+		msg,
+	)
+	finish(err)
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	if confirm != nil {
+		p.awaitConfirm(ctx, event, confirm)
+	}
+
+	slog.DebugContext(ctx, "published event", "event_type", event.Type, "item_id", event.Item.ID)
+	return nil
+}
+
+// begin registers a publish, failing once Flush has been called
+func (p *Publisher) begin() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return fmt.Errorf("publisher is closed")
+	}
+	p.inflight++
+	return nil
+}
+
+// end unregisters a publish and wakes Flush after the last one
+func (p *Publisher) end() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inflight--
+	if p.inflight == 0 && p.drained != nil {
+		close(p.drained)
+		p.drained = nil
+	}
+}
+
+// awaitConfirm tracks confirm until the broker answers it, logging events
+// the broker rejected
+func (p *Publisher) awaitConfirm(ctx context.Context, event ItemEvent, confirm *amqp.DeferredConfirmation) {
+	p.mu.Lock()
+	if p.unconfirmed == nil {
+		p.unconfirmed = map[uint64]*amqp.DeferredConfirmation{}
+	}
+	p.unconfirmed[confirm.DeliveryTag] = confirm
+	p.mu.Unlock()
+	go func() {
+		<-confirm.Done()
+		p.mu.Lock()
+		delete(p.unconfirmed, confirm.DeliveryTag)
+		p.mu.Unlock()
+		if !confirm.Acked() {
+			slog.ErrorContext(ctx, "broker rejected event", "event_type", event.Type, "item_id", event.Item.ID)
+		}
+	}()
+}
+
+// NewPublishing encodes event with the current schema version as the
+// persistent AMQP message sent to the broker. The RequestIDHeader of headers
+// becomes the correlation ID.
+func NewPublishing(event ItemEvent, headers amqp.Table) (amqp.Publishing, error) {
+	event.SchemaVersion = EventSchemaVersion
+	body, err := json.Marshal(event)
+	if err != nil {
+		return amqp.Publishing{}, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	requestID, _ := headers[RequestIDHeader].(string)
+	return amqp.Publishing{
+		DeliveryMode:  amqp.Persistent,
+		ContentType:   "application/json",
+		CorrelationId: requestID,
+		Headers:       headers,
+		Body:          body,
+	}, nil
+}
+
+// routingKey addresses the queue directly on the default exchange and
+// routes by "<tenant>.<event type>" otherwise
+func (p *Publisher) routingKey(event ItemEvent) string {
+	if p.exchange == "" {
+		return p.queue.Name
+	}
+	tenant := event.TenantID
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	return tenant + "." + string(event.Type)
+}
+
+// Flush rejects new publishes, then waits until the running ones have
+// finished and the broker has confirmed every published event, or ctx ends
+func (p *Publisher) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	var drained <-chan struct{}
+	if p.inflight > 0 {
+		if p.drained == nil {
+			p.drained = make(chan struct{})
+		}
+		drained = p.drained
+	}
+	p.mu.Unlock()
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return fmt.Errorf("failed to flush events: %w", ctx.Err())
+		}
+	}
+
+	p.mu.Lock()
+	confirms := make([]*amqp.DeferredConfirmation, 0, len(p.unconfirmed))
+	for _, confirm := range p.unconfirmed {
+		confirms = append(confirms, confirm)
+	}
+	p.mu.Unlock()
+	var nacked int
+	for i, confirm := range confirms {
+		acked, err := confirm.WaitContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to flush events: %d not confirmed by the broker: %w", len(confirms)-i, err)
+		}
+		if !acked {
+			nacked++
+		}
+	}
+	if nacked > 0 {
+		return fmt.Errorf("failed to flush events: the broker rejected %d", nacked)
+	}
+	return nil
+}
+
+// Ready reports whether the connection and channel are open
+func (p *Publisher) Ready() error {
+	if p.conn == nil || p.conn.IsClosed() {
+		return fmt.Errorf("connection is closed")
+	}
+	if p.channel == nil || p.channel.IsClosed() {
+		return fmt.Errorf("channel is closed")
+	}
+	return nil
+}
+
+// Conn returns the publisher's connection
+func (p *Publisher) Conn() *amqp.Connection {
+	return p.conn
+}
+
+// Channel returns the publisher's channel
+func (p *Publisher) Channel() *amqp.Channel {
+	return p.channel
+}
+
+// Close closes the connection and channel
+func (p *Publisher) Close() error {
+	if p.channel != nil {
+		if err := p.channel.Close(); err != nil {
+			return err
+		}
+	}
+	if p.conn != nil {
+		if err := p.conn.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
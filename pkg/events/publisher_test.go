@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestPublisherFlush(t *testing.T) {
+	t.Run("RejectsPublishAfterFlush", func(t *testing.T) {
+		publisher := &Publisher{}
+		if err := publisher.Flush(context.Background()); err != nil {
+			t.Fatalf("Unexpected flush error: %v", err)
+		}
+		if err := publisher.Publish(ItemEvent{Type: EventItemCreated}); err == nil {
+			t.Error("Expected error when publishing after flush")
+		}
+	})
+
+	t.Run("WaitsForInFlightPublish", func(t *testing.T) {
+		// Arrange
+		publisher := &Publisher{}
+		publisher.begin()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		// Act & Assert
+		if err := publisher.Flush(ctx); err == nil {
+			t.Error("Expected flush to time out while a publish is in flight")
+		}
+		time.AfterFunc(10*time.Millisecond, publisher.end)
+		if err := publisher.Flush(context.Background()); err != nil {
+			t.Errorf("Expected flush to finish with the publish; got %v", err)
+		}
+	})
+
+	t.Run("WaitsForConfirms", func(t *testing.T) {
+		// Arrange: a confirmation the broker never answers
+		publisher := &Publisher{}
+		publisher.awaitConfirm(context.Background(), ItemEvent{Type: EventItemCreated}, &amqp.DeferredConfirmation{DeliveryTag: 1})
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		// Act
+		err := publisher.Flush(ctx)
+
+		// Assert
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected flush to time out waiting for the confirm; got %v", err)
+		}
+	})
+}
+
+func TestTenantRoutingKey(t *testing.T) {
+	p := &Publisher{exchange: "items"}
+	if got := p.routingKey(ItemEvent{Type: EventItemCreated, TenantID: "acme"}); got != "acme.item.created" {
+		t.Errorf("Expected acme.item.created; got %s", got)
+	}
+	if got := p.routingKey(ItemEvent{Type: EventItemDeleted}); got != "default.item.deleted" {
+		t.Errorf("Expected default.item.deleted; got %s", got)
+	}
+
+	queue := &Publisher{queue: amqp.Queue{Name: "item_events"}}
+	if got := queue.routingKey(ItemEvent{Type: EventItemCreated, TenantID: "acme"}); got != "item_events" {
+		t.Errorf("Expected the queue name on the default exchange; got %s", got)
+	}
+}
+
+func TestNewPublishing(t *testing.T) {
+	// Arrange
+	headers := amqp.Table{RequestIDHeader: "req-1", TraceparentHeader: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}
+
+	// Act
+	msg, err := NewPublishing(ItemEvent{Type: EventItemCreated, TenantID: "acme", Item: Item{ID: 1, Name: "Anvil"}}, headers)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if msg.DeliveryMode != amqp.Persistent || msg.ContentType != "application/json" {
+		t.Errorf("Expected a persistent JSON message; got %+v", msg)
+	}
+	if msg.CorrelationId != "req-1" || msg.Headers[TraceparentHeader] == nil {
+		t.Errorf("Expected the request ID as correlation ID and the headers; got %q %v", msg.CorrelationId, msg.Headers)
+	}
+	var event ItemEvent
+	json.Unmarshal(msg.Body, &event)
+	if event.TenantID != "acme" || event.Item.Name != "Anvil" {
+		t.Errorf("Unexpected body: %s", msg.Body)
+	}
+}
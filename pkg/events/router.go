@@ -0,0 +1,194 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ErrDeadLetter makes the consumer reject a delivery without requeueing,
+// so it is dead-lettered when the queue has a dead-letter exchange. Wrap it
+// in handler errors that retrying cannot fix.
+var ErrDeadLetter = errors.New("dead-letter event")
+
+// HandlerFunc handles one event
+type HandlerFunc func(ctx context.Context, event ItemEvent) error
+
+// Middleware wraps a handler, e.g. with logging or a timeout
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// UnhandledPolicy is what a Router does with events no handler matches
+type UnhandledPolicy string
+
+const (
+	// UnhandledAck acknowledges and drops the event
+	UnhandledAck UnhandledPolicy = "ack"
+	// UnhandledNack requeues the event for another consumer
+	UnhandledNack UnhandledPolicy = "nack"
+	// UnhandledDeadLetter rejects the event without requeueing
+	UnhandledDeadLetter UnhandledPolicy = "dead-letter"
+)
+
+// route is a handler registered for a pattern
+type route struct {
+	pattern string
+	handler HandlerFunc
+}
+
+// Router dispatches events to handlers registered per EventType or
+// wildcard pattern. Pass its HandleEvent to Consumer.ConsumeContext.
+type Router struct {
+	exact      map[EventType]HandlerFunc
+	patterns   []route
+	middleware []Middleware
+	unhandled  UnhandledPolicy
+}
+
+// NewRouter creates a router applying policy to unmatched events
+func NewRouter(policy UnhandledPolicy) (*Router, error) {
+	switch policy {
+	case UnhandledAck, UnhandledNack, UnhandledDeadLetter:
+	default:
+		return nil, fmt.Errorf("unhandled event policy must be ack, nack or dead-letter; got %q", policy)
+	}
+	return &Router{exact: map[EventType]HandlerFunc{}, unhandled: policy}, nil
+}
+
+// Use adds middleware run around every handler, outermost first
+func (r *Router) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Handle registers handler for pattern, wrapped in mw inside the router's
+// middleware. Patterns are event types whose dot-separated words may be "*"
+// for exactly one word or "#" for zero or more, as in AMQP topic bindings.
+// Exact types take precedence; patterns are tried in registration order.
+func (r *Router) Handle(pattern string, handler HandlerFunc, mw ...Middleware) {
+	handler = chain(handler, mw)
+	if !strings.ContainsAny(pattern, "*#") {
+		r.exact[EventType(pattern)] = handler
+		return
+	}
+	r.patterns = append(r.patterns, route{pattern: pattern, handler: handler})
+}
+
+// HandleEvent dispatches event to its handler through the middleware, or
+// applies the unhandled policy
+func (r *Router) HandleEvent(ctx context.Context, event ItemEvent) error {
+	handler := r.match(event.Type)
+	if handler == nil {
+		handler = r.unhandledHandler
+	}
+	return chain(handler, r.middleware)(ctx, event)
+}
+
+// match returns the handler for eventType, or nil
+func (r *Router) match(eventType EventType) HandlerFunc {
+	if handler, ok := r.exact[eventType]; ok {
+		return handler
+	}
+	for _, route := range r.patterns {
+		if matchTopic(strings.Split(route.pattern, "."), strings.Split(string(eventType), ".")) {
+			return route.handler
+		}
+	}
+	return nil
+}
+
+// unhandledHandler applies the unhandled policy
+func (r *Router) unhandledHandler(ctx context.Context, event ItemEvent) error {
+	switch r.unhandled {
+	case UnhandledNack:
+		return fmt.Errorf("no handler for event type %q", event.Type)
+	case UnhandledDeadLetter:
+		return fmt.Errorf("%w: no handler for event type %q", ErrDeadLetter, event.Type)
+	}
+	slog.DebugContext(ctx, "acknowledging unhandled event", "event_type", event.Type)
+	return nil
+}
+
+// matchTopic matches words against pattern words with AMQP topic wildcards
+func matchTopic(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchTopic(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchTopic(pattern[1:], words[1:])
+	}
+	return len(words) > 0 && pattern[0] == words[0] && matchTopic(pattern[1:], words[1:])
+}
+
+// chain wraps handler in mw, the first being outermost
+func chain(handler HandlerFunc, mw []Middleware) HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+	return handler
+}
+
+// Logging logs every handled event with its duration and error
+func Logging() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event ItemEvent) error {
+			start := time.Now()
+			err := next(ctx, event)
+			attrs := []any{"event_type", event.Type, "item_id", event.Item.ID, "duration_ms", time.Since(start).Milliseconds()}
+			if err != nil {
+				slog.ErrorContext(ctx, "event handler failed", append(attrs, "error", err)...)
+			} else {
+				slog.InfoContext(ctx, "event handled", attrs...)
+			}
+			return err
+		}
+	}
+}
+
+// Recovery turns a panicking handler into an error wrapping ErrHandlerPanic.
+// The consumer recovers panics itself; Recovery lets middleware further out,
+// such as Logging or Metrics, see them as errors.
+func Recovery() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event ItemEvent) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = recoverHandler(ctx, event, p)
+				}
+			}()
+			return next(ctx, event)
+		}
+	}
+}
+
+// Timeout cancels the handler's context after d
+func Timeout(d time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event ItemEvent) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, event)
+		}
+	}
+}
+
+// Metrics calls record with every handled event's type, duration and error
+func Metrics(record func(eventType EventType, duration time.Duration, err error)) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event ItemEvent) error {
+			start := time.Now()
+			err := next(ctx, event)
+			record(event.Type, time.Since(start), err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// record returns a handler appending name to calls
+func record(calls *[]string, name string) HandlerFunc {
+	return func(context.Context, ItemEvent) error {
+		*calls = append(*calls, name)
+		return nil
+	}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	// Arrange
+	var calls []string
+	r, _ := NewRouter(UnhandledAck)
+	r.Handle("item.#", record(&calls, "any item"))
+	r.Handle(string(EventItemCreated), record(&calls, "created"))
+	r.Handle("*.deleted", record(&calls, "deleted"))
+
+	tests := []struct {
+		eventType EventType
+		want      string
+	}{
+		{EventItemCreated, "created"},
+		{EventItemUpdated, "any item"},
+		{EventItemDeleted, "any item"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.eventType), func(t *testing.T) {
+			// Act
+			calls = nil
+			err := r.HandleEvent(context.Background(), ItemEvent{Type: tt.eventType})
+
+			// Assert
+			if err != nil || len(calls) != 1 || calls[0] != tt.want {
+				t.Errorf("Expected handler %q; got %v (%v)", tt.want, calls, err)
+			}
+		})
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern, eventType string
+		want               bool
+	}{
+		{"item.*", "item.created", true},
+		{"item.*", "item.created.v2", false},
+		{"*", "item.created", false},
+		{"#", "item.created", true},
+		{"item.#", "item", true},
+		{"#.created", "item.created", true},
+		{"order.*", "item.created", false},
+	}
+	for _, tt := range tests {
+		if got := matchTopic(strings.Split(tt.pattern, "."), strings.Split(tt.eventType, ".")); got != tt.want {
+			t.Errorf("matchTopic(%q, %q): expected %v; got %v", tt.pattern, tt.eventType, tt.want, got)
+		}
+	}
+}
+
+func TestRouterUnhandledPolicy(t *testing.T) {
+	tests := []struct {
+		policy      UnhandledPolicy
+		wantAcked   bool
+		wantRequeue bool
+	}{
+		{UnhandledAck, true, false},
+		{UnhandledNack, false, true},
+		{UnhandledDeadLetter, false, false},
+	}
+	body := []byte(`{"schemaVersion":2,"type":"item.archived","item":{"id":1}}`)
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			// Arrange
+			r, err := NewRouter(tt.policy)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			r.Handle(string(EventItemCreated), func(context.Context, ItemEvent) error { return nil })
+			ack := &fakeAcknowledger{}
+
+			// Act
+			(&Consumer{}).handleDelivery(ContextHandler(r.HandleEvent), amqp.Delivery{Acknowledger: ack, Body: body})
+
+			// Assert
+			if ack.acked != tt.wantAcked || ack.nacked == tt.wantAcked || ack.requeue != tt.wantRequeue {
+				t.Errorf("Unexpected settlement: %+v", ack)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := NewRouter("drop"); err == nil {
+			t.Error("Expected error for an unknown policy")
+		}
+	})
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	t.Run("Order", func(t *testing.T) {
+		// Arrange
+		var calls []string
+		mw := func(name string) Middleware {
+			return func(next HandlerFunc) HandlerFunc {
+				return func(ctx context.Context, event ItemEvent) error {
+					calls = append(calls, name)
+					return next(ctx, event)
+				}
+			}
+		}
+		r, _ := NewRouter(UnhandledAck)
+		r.Use(mw("outer"), mw("inner"))
+		r.Handle(string(EventItemCreated), record(&calls, "handler"), mw("route"))
+
+		// Act
+		r.HandleEvent(context.Background(), ItemEvent{Type: EventItemCreated})
+
+		// Assert
+		if got := strings.Join(calls, ","); got != "outer,inner,route,handler" {
+			t.Errorf("Unexpected middleware order: %s", got)
+		}
+	})
+
+	t.Run("Recovery", func(t *testing.T) {
+		h := Recovery()(func(context.Context, ItemEvent) error { panic("boom") })
+		if err := h(context.Background(), ItemEvent{}); !errors.Is(err, ErrHandlerPanic) || !strings.Contains(err.Error(), "boom") {
+			t.Errorf("Expected the panic as error; got %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		h := Timeout(10 * time.Millisecond)(func(ctx context.Context, _ ItemEvent) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		if err := h(context.Background(), ItemEvent{}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected deadline exceeded; got %v", err)
+		}
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		var got string
+		h := Metrics(func(eventType EventType, _ time.Duration, err error) {
+			got = fmt.Sprintf("%s %v", eventType, err)
+		})(func(context.Context, ItemEvent) error { return errors.New("failed") })
+		h(context.Background(), ItemEvent{Type: EventItemUpdated})
+		if got != "item.updated failed" {
+			t.Errorf("Unexpected recorded values: %s", got)
+		}
+	})
+
+	t.Run("Logging", func(t *testing.T) {
+		err := errors.New("failed")
+		h := Logging()(func(context.Context, ItemEvent) error { return err })
+		if got := h(context.Background(), ItemEvent{}); got != err {
+			t.Errorf("Expected the handler's error; got %v", got)
+		}
+	})
+}
+
+func TestHandlerDeadLetter(t *testing.T) {
+	ack := &fakeAcknowledger{}
+	(&Consumer{}).handleDelivery(ContextHandler(func(context.Context, ItemEvent) error {
+		return fmt.Errorf("item name is invalid: %w", ErrDeadLetter)
+	}), amqp.Delivery{Acknowledger: ack, Body: []byte(`{"schemaVersion":2,"type":"item.created"}`)})
+	if !ack.nacked || ack.requeue {
+		t.Errorf("Expected delivery to be rejected without requeue; got %+v", ack)
+	}
+}
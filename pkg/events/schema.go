@@ -0,0 +1,153 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EventSchemaVersion is the schemaVersion of events published by this build.
+// Version 1 covers events published before schemaVersion existed.
+const EventSchemaVersion = 2
+
+// Policies for events with a schemaVersion newer than EventSchemaVersion
+const (
+	// UnknownVersionReject rejects the delivery without requeueing, so it is
+	// dead-lettered when the queue has a dead-letter exchange
+	UnknownVersionReject = "reject"
+	// UnknownVersionPass decodes the known fields into ItemEvent and passes it
+	// to the handler; the raw body is available from RawEventFromContext
+	UnknownVersionPass = "pass"
+)
+
+// ErrUnknownSchemaVersion is returned for events newer than the registry knows
+var ErrUnknownSchemaVersion = errors.New("unknown event schema version")
+
+// EventUpcaster converts a decoded payload of one version into the payload
+// of the next version
+type EventUpcaster func(payload any) (any, error)
+
+// eventSchema is one registered payload version
+type eventSchema struct {
+	newPayload func() any
+	upcast     EventUpcaster
+}
+
+// EventSchemaRegistry maps schema versions to payload types and the
+// upcasters that bring them to the current ItemEvent
+type EventSchemaRegistry struct {
+	current  int
+	versions map[int]eventSchema
+}
+
+// NewEventSchemaRegistry creates a registry whose newest version is current
+// and decodes into ItemEvent
+func NewEventSchemaRegistry(current int) *EventSchemaRegistry {
+	r := &EventSchemaRegistry{current: current, versions: map[int]eventSchema{}}
+	r.Register(current, func() any { return &ItemEvent{} }, nil)
+	return r
+}
+
+// Register adds payload type of version, decoded into the value returned by
+// newPayload. upcast converts it to version+1 and is unused for the current
+// version.
+func (r *EventSchemaRegistry) Register(version int, newPayload func() any, upcast EventUpcaster) {
+	r.versions[version] = eventSchema{newPayload: newPayload, upcast: upcast}
+}
+
+// Decode parses body as whatever version it declares and upcasts it to the
+// current ItemEvent. Bodies without a positive schemaVersion are version 1.
+// Versions newer than current return ErrUnknownSchemaVersion.
+func (r *EventSchemaRegistry) Decode(body []byte) (ItemEvent, int, error) {
+	var envelope struct {
+		SchemaVersion *int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return ItemEvent{}, 0, fmt.Errorf("failed to decode event: %w", err)
+	}
+	version := 1
+	if envelope.SchemaVersion != nil && *envelope.SchemaVersion > 0 {
+		version = *envelope.SchemaVersion
+	}
+	if version > r.current {
+		return ItemEvent{}, version, fmt.Errorf("%w %d (newest known is %d)", ErrUnknownSchemaVersion, version, r.current)
+	}
+
+	schema, ok := r.versions[version]
+	if !ok {
+		return ItemEvent{}, version, fmt.Errorf("event schema version %d is not registered", version)
+	}
+	payload := schema.newPayload()
+	if err := json.Unmarshal(body, payload); err != nil {
+		return ItemEvent{}, version, fmt.Errorf("failed to decode version %d event: %w", version, err)
+	}
+	for v := version; v < r.current; v++ {
+		schema, ok := r.versions[v]
+		if !ok || schema.upcast == nil {
+			return ItemEvent{}, version, fmt.Errorf("no upcaster from event schema version %d", v)
+		}
+		var err error
+		if payload, err = schema.upcast(payload); err != nil {
+			return ItemEvent{}, version, fmt.Errorf("failed to upcast event from version %d: %w", v, err)
+		}
+	}
+	event, ok := payload.(*ItemEvent)
+	if !ok {
+		return ItemEvent{}, version, fmt.Errorf("version %d event upcast to %T instead of ItemEvent", version, payload)
+	}
+	event.SchemaVersion = r.current
+	return *event, version, nil
+}
+
+// itemEventV1 is the payload published before schemaVersion; events from
+// before multi-tenancy also lack tenantId
+type itemEventV1 struct {
+	Type      EventType `json:"type"`
+	TenantID  string    `json:"tenantId,omitempty"`
+	Item      Item      `json:"item"`
+	Timestamp time.Time `json:"timestamp"`
+	Actor     string    `json:"actor,omitempty"`
+}
+
+// upcastItemEventV1 assigns version 1 events without a tenant to the default
+// tenant, which held every item before multi-tenancy
+func upcastItemEventV1(payload any) (any, error) {
+	v1 := payload.(*itemEventV1)
+	if v1.TenantID == "" {
+		v1.TenantID = DefaultTenant
+	}
+	return &ItemEvent{
+		Type:      v1.Type,
+		TenantID:  v1.TenantID,
+		Item:      v1.Item,
+		Timestamp: v1.Timestamp,
+		Actor:     v1.Actor,
+	}, nil
+}
+
+// defaultSchemas is the registry used by consumers without their own
+var defaultSchemas = NewItemEventSchemas()
+
+// NewItemEventSchemas returns a registry of every published version of
+// ItemEvent, to which consumers may add their own
+func NewItemEventSchemas() *EventSchemaRegistry {
+	r := NewEventSchemaRegistry(EventSchemaVersion)
+	r.Register(1, func() any { return &itemEventV1{} }, upcastItemEventV1)
+	return r
+}
+
+// rawEventKey is the context key of the undecoded event body
+type rawEventKey struct{}
+
+// withRawEvent returns ctx carrying the undecoded body of the delivery
+func withRawEvent(ctx context.Context, body []byte) context.Context {
+	return context.WithValue(ctx, rawEventKey{}, json.RawMessage(body))
+}
+
+// RawEventFromContext returns the undecoded body of the event being handled
+func RawEventFromContext(ctx context.Context) json.RawMessage {
+	body, _ := ctx.Value(rawEventKey{}).(json.RawMessage)
+	return body
+}
@@ -0,0 +1,147 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestEventSchemaRegistryDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantVersion int
+		wantTenant  string
+	}{
+		{"Current", `{"schemaVersion":2,"type":"item.created","tenantId":"acme","item":{"id":1,"name":"Anvil"},"timestamp":"2026-01-02T03:04:05Z"}`, 2, "acme"},
+		{"V1BeforeTenancy", `{"type":"item.created","item":{"id":1,"name":"Anvil"},"timestamp":"2026-01-02T03:04:05Z"}`, 1, DefaultTenant},
+		{"V1WithTenant", `{"type":"item.created","tenantId":"acme","item":{"id":1,"name":"Anvil"},"timestamp":"2026-01-02T03:04:05Z"}`, 1, "acme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			event, version, err := defaultSchemas.Decode([]byte(tt.body))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if version != tt.wantVersion {
+				t.Errorf("Expected version %d; got %d", tt.wantVersion, version)
+			}
+			if event.SchemaVersion != EventSchemaVersion || event.TenantID != tt.wantTenant || event.Item.Name != "Anvil" || event.Timestamp.IsZero() {
+				t.Errorf("Unexpected event: %+v", event)
+			}
+		})
+	}
+
+	t.Run("UnknownVersion", func(t *testing.T) {
+		_, version, err := defaultSchemas.Decode([]byte(`{"schemaVersion":99,"type":"item.created"}`))
+		if !errors.Is(err, ErrUnknownSchemaVersion) || version != 99 {
+			t.Errorf("Expected unknown schema version 99; got %d %v", version, err)
+		}
+	})
+
+	t.Run("MalformedBody", func(t *testing.T) {
+		if _, _, err := defaultSchemas.Decode([]byte("not json")); err == nil {
+			t.Error("Expected decode error")
+		}
+	})
+}
+
+func TestEventSchemaRegistryUpcastChain(t *testing.T) {
+	// Arrange
+	type v1 struct {
+		Label string `json:"label"`
+	}
+	type v2 struct {
+		Name string `json:"name"`
+	}
+	r := NewEventSchemaRegistry(3)
+	r.Register(1, func() any { return &v1{} }, func(p any) (any, error) {
+		return &v2{Name: p.(*v1).Label}, nil
+	})
+	r.Register(2, func() any { return &v2{} }, func(p any) (any, error) {
+		return &ItemEvent{Type: EventItemCreated, Item: Item{Name: p.(*v2).Name}}, nil
+	})
+
+	// Act
+	event, version, err := r.Decode([]byte(`{"schemaVersion":1,"label":"Anvil"}`))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if version != 1 || event.SchemaVersion != 3 || event.Item.Name != "Anvil" {
+		t.Errorf("Expected version 1 upcast to 3; got %d %+v", version, event)
+	}
+
+	t.Run("MissingUpcaster", func(t *testing.T) {
+		r := NewEventSchemaRegistry(3)
+		r.Register(1, func() any { return &v1{} }, nil)
+		if _, _, err := r.Decode([]byte(`{"schemaVersion":1,"label":"Anvil"}`)); err == nil {
+			t.Error("Expected error without an upcaster")
+		}
+	})
+}
+
+func TestEventConsumerSchemaVersions(t *testing.T) {
+	newer := []byte(`{"schemaVersion":99,"type":"item.created","tenantId":"acme","item":{"id":3,"name":"Anvil"},"colour":"red"}`)
+
+	t.Run("UpcastsOldEvents", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		var got ItemEvent
+		(&Consumer{}).handleDelivery(ContextHandler(func(_ context.Context, event ItemEvent) error {
+			got = event
+			return nil
+		}), amqp.Delivery{Acknowledger: ack, Body: []byte(`{"type":"item.deleted","item":{"id":3,"name":"Anvil"}}`)})
+
+		if !ack.acked || got.TenantID != DefaultTenant || got.SchemaVersion != EventSchemaVersion {
+			t.Errorf("Expected an acked, upcast event; got %+v %+v", ack, got)
+		}
+	})
+
+	t.Run("RejectsUnknownVersion", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		(&Consumer{opts: ConsumerOptions{UnknownVersion: UnknownVersionReject}}).handleDelivery(ContextHandler(func(_ context.Context, event ItemEvent) error {
+			t.Error("Handler must not be called for an unknown version")
+			return nil
+		}), amqp.Delivery{Acknowledger: ack, Body: newer})
+
+		if !ack.nacked || ack.requeue {
+			t.Errorf("Expected delivery to be rejected without requeue; got %+v", ack)
+		}
+	})
+
+	t.Run("PassesUnknownVersion", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		var got ItemEvent
+		var raw json.RawMessage
+		(&Consumer{opts: ConsumerOptions{UnknownVersion: UnknownVersionPass}}).handleDelivery(ContextHandler(func(ctx context.Context, event ItemEvent) error {
+			got, raw = event, RawEventFromContext(ctx)
+			return nil
+		}), amqp.Delivery{Acknowledger: ack, Body: newer})
+
+		if !ack.acked || got.SchemaVersion != 99 || got.Item.Name != "Anvil" {
+			t.Errorf("Expected the known fields to be passed; got %+v %+v", ack, got)
+		}
+		if string(raw) != string(newer) {
+			t.Errorf("Expected the raw body in the context; got %s", raw)
+		}
+	})
+}
+
+func TestNewPublishingStampsSchemaVersion(t *testing.T) {
+	msg, err := NewPublishing(ItemEvent{Type: EventItemCreated}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var event ItemEvent
+	json.Unmarshal(msg.Body, &event)
+	if event.SchemaVersion != EventSchemaVersion {
+		t.Errorf("Expected schema version %d; got %d", EventSchemaVersion, event.SchemaVersion)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Route classes limited independently. RouteClassAuth counts failed
+// authentications per client IP on every authenticated route.
+const (
+	RouteClassRead  = "read"
+	RouteClassWrite = "write"
+	RouteClassAuth  = "auth"
+)
+
+// What rate limit buckets are keyed by
+const (
+	RateLimitByPrincipal = "principal"
+	RateLimitByTenant    = "tenant"
+	RateLimitByIP        = "ip"
+)
+
+// maxIdleBuckets bounds the buckets kept before full, idle ones are dropped
+const maxIdleBuckets = 10000
+
+// tokenBucket is one client's bucket
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a token bucket limiter holding one bucket per client key
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// NewRateLimiter allows perMinute requests per minute with bursts of burst
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// RateLimitDecision describes the state of a bucket after a request
+type RateLimitDecision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed
+}
+
+// Allow takes a token from key's bucket if one is available
+func (l *RateLimiter) Allow(key string) RateLimitDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.sweepLocked(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	d := RateLimitDecision{Limit: int(l.burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = l.durationFor(1 - b.tokens)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = l.durationFor(l.burst - b.tokens)
+	return d
+}
+
+// durationFor returns how long refilling tokens takes
+func (l *RateLimiter) durationFor(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweepLocked drops buckets that have refilled completely
+func (l *RateLimiter) sweepLocked(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimiters holds the limiter per route class; nil disables rate limiting
+var rateLimiters map[string]*RateLimiter
+
+// rateLimitKey selects how clients are told apart
+var rateLimitKey = RateLimitByPrincipal
+
+// newRateLimiters builds the per-class limiters from configuration
+func newRateLimiters(cfg RateLimitConfig) map[string]*RateLimiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	limiters := map[string]*RateLimiter{
+		RouteClassRead:  NewRateLimiter(cfg.ReadPerMinute, cfg.ReadBurst),
+		RouteClassWrite: NewRateLimiter(cfg.WritePerMinute, cfg.WriteBurst),
+	}
+	if cfg.AuthFailuresPerMinute > 0 && cfg.AuthFailureBurst > 0 {
+		limiters[RouteClassAuth] = NewRateLimiter(cfg.AuthFailuresPerMinute, cfg.AuthFailureBurst)
+	}
+	return limiters
+}
+
+// clientKey identifies the client a request is counted against, falling
+// back to the client IP when the request has no principal or valid tenant
+func clientKey(r *http.Request) string {
+	switch rateLimitKey {
+	case RateLimitByPrincipal:
+		if p := principalFromContext(r.Context()); p != nil {
+			return "principal:" + p.Subject
+		}
+	case RateLimitByTenant:
+		if tenant, err := resolveTenant(r); err == nil {
+			return "tenant:" + tenant
+		}
+	}
+	return "ip:" + sourceIP(r)
+}
+
+// rateLimit rejects requests over the class's limit with a 429 problem
+// response; it passes every request through when rate limiting is disabled
+func rateLimit(class string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limiter := rateLimiters[class]
+		if limiter == nil {
+			next(w, r)
+			return
+		}
+		d := limiter.Allow(clientKey(r))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+		if !d.Allowed {
+			rateLimitedTotal.Inc(class)
+			detail := fmt.Sprintf("rate limit of %d %s requests exceeded", d.Limit, class)
+			auditRouteDenied(r, detail)
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+			writeProblem(w, r, http.StatusTooManyRequests, detail)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authFailureKey is the bucket failed authentications of r are counted in.
+// It is always the client IP, as a failed request has no principal. The IP
+// is the connection's peer; X-Forwarded-For is not trusted, so behind a
+// proxy all of its clients share one bucket.
+func authFailureKey(r *http.Request) string {
+	return "ip:" + sourceIP(r)
+}
+
+// throttleAuthFailure counts a failed authentication of r against its client
+// IP and, once the IP has used up its failed authentications, rejects r with
+// a 429 problem response instead of a 401. Only requests whose credentials
+// were already rejected reach it, so valid credentials are never throttled.
+func throttleAuthFailure(w http.ResponseWriter, r *http.Request) bool {
+	limiter := rateLimiters[RouteClassAuth]
+	if limiter == nil {
+		return false
+	}
+	d := limiter.Allow(authFailureKey(r))
+	if d.Allowed {
+		return false
+	}
+	rateLimitedTotal.Inc(RouteClassAuth)
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+	writeProblem(w, r, http.StatusTooManyRequests, "too many failed authentication attempts")
+	return true
+}
+
+// ceilSeconds rounds d up to whole seconds for rate limit headers
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useRateLimiters enables rate limiting for the test
+func useRateLimiters(t *testing.T, key string, cfg RateLimitConfig) {
+	t.Helper()
+	cfg.Enabled = true
+	previous, previousKey := rateLimiters, rateLimitKey
+	rateLimiters, rateLimitKey = newRateLimiters(cfg), key
+	t.Cleanup(func() { rateLimiters, rateLimitKey = previous, previousKey })
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	// Arrange
+	now := time.Unix(0, 0)
+	l := NewRateLimiter(60, 2)
+	l.now = func() time.Time { return now }
+
+	// Act & Assert
+	for i := 0; i < 2; i++ {
+		if d := l.Allow("a"); !d.Allowed {
+			t.Fatalf("Expected request %d within the burst to be allowed", i+1)
+		}
+	}
+	d := l.Allow("a")
+	if d.Allowed || d.Remaining != 0 || d.RetryAfter != time.Second {
+		t.Errorf("Expected request over the burst to wait 1s; got %+v", d)
+	}
+	if d := l.Allow("b"); !d.Allowed {
+		t.Error("Expected another key to have its own bucket")
+	}
+
+	now = now.Add(time.Second)
+	if d := l.Allow("a"); !d.Allowed {
+		t.Error("Expected a token to be refilled after 1s")
+	}
+
+	now = now.Add(time.Hour)
+	if d := l.Allow("a"); d.Remaining != 1 || d.Limit != 2 {
+		t.Errorf("Expected the bucket to refill to its burst only; got %+v", d)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	// Arrange
+	useRateLimiters(t, RateLimitByIP, RateLimitConfig{ReadPerMinute: 60, ReadBurst: 1, WritePerMinute: 60, WriteBurst: 1})
+	useStore(t, 0)
+	mux := newMux()
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	throttledBefore := rateLimitedTotal.Value(RouteClassRead)
+
+	// Act
+	first := get("192.0.2.1:1234")
+	second := get("192.0.2.1:5678")
+	other := get("192.0.2.2:1234")
+
+	// Assert
+	if first.Code != http.StatusOK || first.Header().Get("RateLimit-Limit") != "1" || first.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected first request to pass with rate limit headers; got %v %v", first.Code, first.Header())
+	}
+	if second.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status Too Many Requests; got %v", second.Code)
+	}
+	if got := second.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Expected Retry-After 1; got %q", got)
+	}
+	var problem Problem
+	if err := json.NewDecoder(second.Body).Decode(&problem); err != nil || problem.Status != http.StatusTooManyRequests {
+		t.Errorf("Expected problem response; got %+v (%v)", problem, err)
+	}
+	if got := rateLimitedTotal.Value(RouteClassRead); got != throttledBefore+1 {
+		t.Errorf("Expected throttled request to be counted; got %v -> %v", throttledBefore, got)
+	}
+	if other.Code != http.StatusOK {
+		t.Errorf("Expected another client to be unaffected; got %v", other.Code)
+	}
+}
+
+func TestRateLimitRouteClasses(t *testing.T) {
+	// Arrange
+	useRateLimiters(t, RateLimitByIP, RateLimitConfig{ReadPerMinute: 60, ReadBurst: 5, WritePerMinute: 60, WriteBurst: 1})
+	useStore(t, 0)
+	eventPublisher = nil
+	mux := newMux()
+	do := func(method, path string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec.Code
+	}
+
+	// Act & Assert
+	do(http.MethodPost, "/items/add")
+	if code := do(http.MethodPost, "/items/add"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected second write to be throttled; got %v", code)
+	}
+	if code := do(http.MethodGet, "/items"); code != http.StatusOK {
+		t.Errorf("Expected reads to have their own limit; got %v", code)
+	}
+}
+
+func TestRateLimitAuthFailures(t *testing.T) {
+	// Arrange
+	useRateLimiters(t, RateLimitByPrincipal, RateLimitConfig{ReadPerMinute: 60, ReadBurst: 100, WritePerMinute: 60, WriteBurst: 100, AuthFailuresPerMinute: 60, AuthFailureBurst: 2})
+	useAuthenticator(t, AuthConfig{Enabled: true, BootstrapAPIKey: "bootstrap-secret"})
+	useStore(t, 0)
+	mux := newMux()
+	get := func(remoteAddr, key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	throttledBefore := rateLimitedTotal.Value(RouteClassAuth)
+
+	// Act
+	codes := []int{get("192.0.2.1:1", "guess-1"), get("192.0.2.1:2", "guess-2"), get("192.0.2.1:3", "guess-3"), get("192.0.2.1:4", "bootstrap-secret")}
+	other := get("192.0.2.2:1", "guess-1")
+
+	// Assert
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusOK}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("Expected request %d to get %d; got %d", i+1, want[i], codes[i])
+		}
+	}
+	if got := rateLimitedTotal.Value(RouteClassAuth); got != throttledBefore+1 {
+		t.Errorf("Expected the rejected attempt to be counted; got %v -> %v", throttledBefore, got)
+	}
+	if other != http.StatusUnauthorized {
+		t.Errorf("Expected another client IP to be unaffected; got %v", other)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set(TenantHeader, "acme")
+	withPrincipalReq := req.WithContext(withPrincipal(req.Context(), &Principal{Subject: "apikey:1"}))
+	tests := []struct {
+		name string
+		key  string
+		req  *http.Request
+		want string
+	}{
+		{"Principal", RateLimitByPrincipal, withPrincipalReq, "principal:apikey:1"},
+		{"PrincipalFallsBackToIP", RateLimitByPrincipal, req, "ip:192.0.2.1"},
+		{"Tenant", RateLimitByTenant, req, "tenant:acme"},
+		{"IP", RateLimitByIP, withPrincipalReq, "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := rateLimitKey
+			rateLimitKey = tt.key
+			defer func() { rateLimitKey = previous }()
+			if got := clientKey(tt.req); got != tt.want {
+				t.Errorf("Expected %s; got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+// shutdownStep is a named action run after the HTTP server stops
+type shutdownStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// publisherShutdownSteps flushes in-flight events, then closes the channel and connection
+func publisherShutdownSteps(ep *events.Publisher) []shutdownStep {
+	if ep == nil {
+		return nil
+	}
+	return []shutdownStep{
+		{name: "flush events", run: ep.Flush},
+		{name: "close event publisher", run: func(context.Context) error { return ep.Close() }},
+	}
+}
+
+// serve runs srv on ln until ctx is cancelled, then stops accepting
+// connections, drains in-flight requests within timeout and runs each
+// shutdown step in order
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, timeout time.Duration, steps ...shutdownStep) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("server failed: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to drain in-flight requests", "error", err)
+		errs = append(errs, fmt.Errorf("drain requests: %w", err))
+		srv.Close()
+	}
+	<-serveErr
+
+	for _, step := range steps {
+		if err := step.run(shutdownCtx); err != nil {
+			slog.Error("shutdown step failed", "step", step.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+		}
+	}
+	slog.Info("server stopped")
+	return errors.Join(errs...)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServeGracefulShutdown(t *testing.T) {
+	// Arrange
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	srv := &http.Server{Handler: mux}
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) shutdownStep {
+		return shutdownStep{name: name, run: func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveDone := make(chan error, 1)
+	go func() {
+		serveDone <- serve(ctx, srv, ln, time.Second, record("flush events"), record("close event publisher"))
+	}()
+
+	respDone := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			respDone <- "error: " + err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		respDone <- string(body)
+	}()
+
+	// Act
+	<-started
+	cancel()
+
+	// Assert
+	if got := <-respDone; got != "done" {
+		t.Errorf("Expected in-flight request to complete; got %q", got)
+	}
+	if err := <-serveDone; err != nil {
+		t.Errorf("Expected clean shutdown; got %v", err)
+	}
+	if len(order) != 2 || order[0] != "flush events" || order[1] != "close event publisher" {
+		t.Errorf("Unexpected shutdown order: %v", order)
+	}
+	if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Error("Expected listener to be closed after shutdown")
+	}
+}
+
+func TestServeShutdownDeadline(t *testing.T) {
+	// Arrange
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	srv := &http.Server{Handler: mux}
+	ctx, cancel := context.WithCancel(context.Background())
+	serveDone := make(chan error, 1)
+	go func() {
+		serveDone <- serve(ctx, srv, ln, 50*time.Millisecond)
+	}()
+	go http.Get("http://" + ln.Addr().String() + "/stuck")
+
+	// Act
+	<-started
+	cancel()
+
+	// Assert
+	select {
+	case err := <-serveDone:
+		if err == nil {
+			t.Error("Expected error when drain deadline is exceeded")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("serve did not return after the drain deadline")
+	}
+}
+
+func TestPublisherShutdownSteps(t *testing.T) {
+	t.Run("NoStepsForNilPublisher", func(t *testing.T) {
+		if steps := publisherShutdownSteps(nil); len(steps) != 0 {
+			t.Errorf("Expected no shutdown steps; got %d", len(steps))
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ItemsSnapshot is a tenant's items as of change Sequence. Events with a
+// greater sequence and the same Epoch are exactly the changes made after
+// the snapshot.
+type ItemsSnapshot struct {
+	Sequence int64          `json:"sequence"`
+	Epoch    int64          `json:"epoch"`
+	TenantID string         `json:"tenantId"`
+	Items    []SnapshotItem `json:"items"`
+}
+
+// SnapshotItem is an item with the version of its latest change
+type SnapshotItem struct {
+	Item    Item `json:"item"`
+	Version int  `json:"version"`
+}
+
+// getItemsSnapshot serves the tenant's items, with include=deleted also
+// those in the trash, together with the sequence of the latest change they
+// reflect
+func getItemsSnapshot(w http.ResponseWriter, r *http.Request) {
+	deleted, err := includeDeleted(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	tenant := tenantFromContext(r.Context())
+	_, span := startSpan(r.Context(), "store.snapshot")
+	span.SetAttribute("tenant.id", tenant)
+	store.mu.Lock()
+	t := store.lookupLocked(tenant)
+	items := t.list(deleted)
+	snapshot := ItemsSnapshot{Sequence: t.sequence, Epoch: store.epoch, TenantID: tenant, Items: make([]SnapshotItem, 0, len(items))}
+	for _, item := range items {
+		snapshot.Items = append(snapshot.Items, SnapshotItem{Item: item, Version: t.versions[item.ID]})
+	}
+	setSequenceHeader(w, tenant)
+	store.mu.Unlock()
+	span.SetAttribute("item.count", len(items))
+	span.SetAttribute("sequence", snapshot.Sequence)
+	span.End()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
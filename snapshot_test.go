@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+func TestGetItemsSnapshot(t *testing.T) {
+	// Arrange
+	useStore(t, 0, Item{ID: 1, Name: "Anvil"}, Item{ID: 2, Name: "Rocket"})
+	store.touchLocked(DefaultTenant, 2)
+	store.addLocked("acme", Item{Name: "Other tenant"})
+	req := httptest.NewRequest(http.MethodGet, "/items/snapshot", nil)
+	rec := httptest.NewRecorder()
+
+	// Act
+	getItemsSnapshot(rec, req)
+
+	// Assert
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", rec.Code)
+	}
+	var got ItemsSnapshot
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if got.Sequence != 3 || got.TenantID != DefaultTenant {
+		t.Errorf("Expected sequence 3 of tenant %s; got %d of %s", DefaultTenant, got.Sequence, got.TenantID)
+	}
+	want := []SnapshotItem{{Item{ID: 1, Name: "Anvil"}, 1}, {Item{ID: 2, Name: "Rocket"}, 2}}
+	if len(got.Items) != len(want) || got.Items[0] != want[0] || got.Items[1] != want[1] {
+		t.Errorf("Expected items %+v; got %+v", want, got.Items)
+	}
+
+	t.Run("NextEventFollowsSnapshot", func(t *testing.T) {
+		store.touchLocked(DefaultTenant, 1)
+		event := itemEvent(context.Background(), events.EventItemUpdated, DefaultTenant, Item{ID: 1, Name: "Anvil"})
+		if event.Sequence != got.Sequence+1 || event.ItemVersion != 2 {
+			t.Errorf("Expected sequence %d and version 2; got %d and %d", got.Sequence+1, event.Sequence, event.ItemVersion)
+		}
+		if got.Epoch == 0 || event.Epoch != got.Epoch {
+			t.Errorf("Expected the event to carry the snapshot's epoch %d; got %d", got.Epoch, event.Epoch)
+		}
+	})
+
+	t.Run("NewStoreHasNewerEpoch", func(t *testing.T) {
+		if restarted := NewItemStore(0); restarted.epoch <= got.Epoch {
+			t.Errorf("Expected an epoch after %d; got %d", got.Epoch, restarted.epoch)
+		}
+	})
+}
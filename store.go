@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// errQuotaExceeded is returned when a tenant has reached its item quota
+var errQuotaExceeded = errors.New("tenant item quota exceeded")
+
+// errItemNotFound is recorded when a request names an item the tenant does
+// not have
+var errItemNotFound = errors.New("item not found")
+
+// ItemStore holds items partitioned by tenant. Handlers hold mu while they
+// read or mutate a tenant and publish the resulting event, so events are
+// emitted in the order changes are applied.
+type ItemStore struct {
+	mu      sync.Mutex
+	tenants map[string]*tenantItems
+	// quota caps the items per tenant; 0 means unlimited
+	quota int
+	// changed is closed and replaced whenever a tenant's sequence advances
+	changed chan struct{}
+	// epoch is the time the store was created in Unix nanoseconds. Item
+	// versions and sequences start over with every store, so events and
+	// snapshots carry the epoch to tell consumers when that happened.
+	epoch int64
+}
+
+// tenantItems is one tenant's items and ID sequence. Deleted items stay in
+// items, with DeletedAt set, until they are purged.
+type tenantItems struct {
+	items  []Item
+	nextID int
+	// versions counts the changes to each item ID, kept after deletion so
+	// events about an ID are always numbered upwards
+	versions map[int]int
+	// sequence numbers the tenant's changes in the order they are applied;
+	// it is the number of the latest change
+	sequence int64
+}
+
+// NewItemStore creates an empty store with the given per-tenant quota
+func NewItemStore(quota int) *ItemStore {
+	return &ItemStore{tenants: make(map[string]*tenantItems), quota: quota, changed: make(chan struct{}), epoch: time.Now().UnixNano()}
+}
+
+// store is the process-wide item store
+var store = NewItemStore(0)
+
+// tenantLocked returns the tenant's items, creating them on first use
+func (s *ItemStore) tenantLocked(tenant string) *tenantItems {
+	t, ok := s.tenants[tenant]
+	if !ok {
+		t = &tenantItems{items: []Item{}, nextID: 1, versions: map[int]int{}}
+		s.tenants[tenant] = t
+	}
+	return t
+}
+
+// lookupLocked returns the tenant's items for reading, or an empty tenant
+// that is not stored when it has none, so reads naming unknown tenants do
+// not create them
+func (s *ItemStore) lookupLocked(tenant string) *tenantItems {
+	if t, ok := s.tenants[tenant]; ok {
+		return t
+	}
+	return &tenantItems{items: []Item{}, nextID: 1, versions: map[int]int{}}
+}
+
+// sequenceLocked returns the number of the tenant's latest change, without
+// creating the tenant
+func (s *ItemStore) sequenceLocked(tenant string) int64 {
+	return s.lookupLocked(tenant).sequence
+}
+
+// addLocked assigns the next tenant-local ID to item and appends it
+func (s *ItemStore) addLocked(tenant string, item Item) (Item, error) {
+	t := s.tenantLocked(tenant)
+	if s.quota > 0 && t.liveCount() >= s.quota {
+		return Item{}, errQuotaExceeded
+	}
+	item.ID = t.nextID
+	item.DeletedAt = nil
+	t.nextID++
+	t.items = append(t.items, item)
+	s.touchLocked(tenant, item.ID)
+	return item, nil
+}
+
+// touchLocked records a change to item id of tenant, bumping the item's
+// version and the tenant's change sequence
+func (s *ItemStore) touchLocked(tenant string, id int) {
+	t := s.tenantLocked(tenant)
+	t.versions[id]++
+	t.sequence++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// liveCount returns the number of items not in the trash
+func (t *tenantItems) liveCount() int {
+	n := 0
+	for _, item := range t.items {
+		if item.DeletedAt == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// list returns the items not in the trash, or all of them with deleted
+func (t *tenantItems) list(deleted bool) []Item {
+	if deleted {
+		return t.items
+	}
+	live := make([]Item, 0, len(t.items))
+	for _, item := range t.items {
+		if item.DeletedAt == nil {
+			live = append(live, item)
+		}
+	}
+	return live
+}
+
+// countLocked returns the number of items not in the trash across all tenants
+func (s *ItemStore) countLocked() int {
+	n := 0
+	for _, t := range s.tenants {
+		n += t.liveCount()
+	}
+	return n
+}
+
+// Count returns the number of items not in the trash across all tenants
+func (s *ItemStore) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.countLocked()
+}
+
+// Ping verifies the store lock can be acquired within timeout
+func (s *ItemStore) Ping(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for !s.mu.TryLock() {
+		if time.Now().After(deadline) {
+			return errors.New("store lock not acquired within timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.mu.Unlock()
+	return nil
+}
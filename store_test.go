@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// useStore installs a fresh store for the test, seeding the default tenant
+func useStore(t *testing.T, quota int, items ...Item) *tenantItems {
+	t.Helper()
+	previous := store
+	store = NewItemStore(quota)
+	t.Cleanup(func() { store = previous })
+	seeded := store.tenantLocked(DefaultTenant)
+	for _, item := range items {
+		seeded.items = append(seeded.items, item)
+		store.touchLocked(DefaultTenant, item.ID)
+		if item.ID >= seeded.nextID {
+			seeded.nextID = item.ID + 1
+		}
+	}
+	return seeded
+}
+
+func TestItemStore(t *testing.T) {
+	t.Run("IDsArePerTenant", func(t *testing.T) {
+		// Arrange
+		s := NewItemStore(0)
+
+		// Act
+		a, _ := s.addLocked("acme", Item{Name: "a"})
+		b, _ := s.addLocked("globex", Item{Name: "b"})
+
+		// Assert
+		if a.ID != 1 || b.ID != 1 {
+			t.Errorf("Expected each tenant to start at ID 1; got %d and %d", a.ID, b.ID)
+		}
+		if got := s.Count(); got != 2 {
+			t.Errorf("Expected 2 items; got %d", got)
+		}
+	})
+
+	t.Run("QuotaExceeded", func(t *testing.T) {
+		// Arrange
+		s := NewItemStore(1)
+		s.addLocked("acme", Item{Name: "a"})
+
+		// Act
+		_, err := s.addLocked("acme", Item{Name: "b"})
+
+		// Assert
+		if !errors.Is(err, errQuotaExceeded) {
+			t.Errorf("Expected quota error; got %v", err)
+		}
+		if _, err := s.addLocked("globex", Item{Name: "c"}); err != nil {
+			t.Errorf("Expected quota to apply per tenant; got %v", err)
+		}
+	})
+
+	t.Run("VersionsCountChanges", func(t *testing.T) {
+		// Arrange
+		s := NewItemStore(0)
+		item, _ := s.addLocked("acme", Item{Name: "a"})
+		s.addLocked("globex", Item{Name: "b"})
+		items := s.tenantLocked("acme")
+
+		// Act
+		s.touchLocked("acme", item.ID)
+		items.items = nil
+		s.touchLocked("acme", item.ID)
+
+		// Assert
+		if got := items.versions[item.ID]; got != 3 {
+			t.Errorf("Expected version 3 after create, update and delete; got %d", got)
+		}
+		if items.sequence != 3 || s.tenantLocked("globex").sequence != 1 {
+			t.Errorf("Expected the sequences to count each tenant's changes; got %d and %d", items.sequence, s.tenantLocked("globex").sequence)
+		}
+	})
+
+	t.Run("PingTimesOutWhileLocked", func(t *testing.T) {
+		// Arrange
+		s := NewItemStore(0)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// Act
+		err := s.Ping(5 * time.Millisecond)
+
+		// Assert
+		if err == nil {
+			t.Error("Expected error when store is locked")
+		}
+	})
+}
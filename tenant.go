@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+// TenantHeader selects the tenant when authentication is disabled and for
+// callers allowed to select one
+const TenantHeader = "X-Tenant-ID"
+
+// DefaultTenant owns requests that name no tenant, and is the tenant
+// consumers assign to events without one
+const DefaultTenant = events.DefaultTenant
+
+// tenantIDPattern keeps tenant IDs safe to embed in AMQP routing keys
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+type tenantKey struct{}
+
+// withTenantContext returns a context carrying the tenant ID
+func withTenantContext(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// tenantFromContext returns the request's tenant, or DefaultTenant
+func tenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+// resolveTenant picks the tenant bound to the principal, falling back to
+// the X-Tenant-ID header and then DefaultTenant. A principal bound to a
+// tenant may not select another one through the header, and an unbound
+// principal may only select one other than DefaultTenant with
+// PermTenantsSelect. Tenants from token claims and certificate mappings are
+// checked like the header.
+func resolveTenant(r *http.Request) (string, error) {
+	requested := r.Header.Get(TenantHeader)
+	if requested != "" && !tenantIDPattern.MatchString(requested) {
+		return "", fmt.Errorf("invalid tenant ID %q", requested)
+	}
+	if p := principalFromContext(r.Context()); p != nil && p.Tenant != "" {
+		if !tenantIDPattern.MatchString(p.Tenant) {
+			return "", fmt.Errorf("credentials are bound to invalid tenant ID %q", p.Tenant)
+		}
+		if requested != "" && requested != p.Tenant {
+			return "", fmt.Errorf("credentials are not valid for tenant %q", requested)
+		}
+		return p.Tenant, nil
+	}
+	if p := principalFromContext(r.Context()); p != nil && requested != "" && requested != DefaultTenant && !policy.Allowed(p, PermTenantsSelect) {
+		return "", fmt.Errorf("credentials are not bound to a tenant and may not select tenant %q", requested)
+	}
+	if requested != "" {
+		return requested, nil
+	}
+	return DefaultTenant, nil
+}
+
+// withTenant resolves the request's tenant and stores it in the context
+func withTenant(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, err := resolveTenant(r)
+		if err != nil {
+			writeProblem(w, r, http.StatusForbidden, err.Error())
+			return
+		}
+		next(w, r.WithContext(withTenantContext(r.Context(), tenant)))
+	}
+}
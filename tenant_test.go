@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveTenant(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		principal *Principal
+		want      string
+		wantErr   bool
+	}{
+		{name: "Default", want: DefaultTenant},
+		{name: "Header", header: "acme", want: "acme"},
+		{name: "InvalidHeader", header: "Acme Corp", wantErr: true},
+		{name: "BoundPrincipal", principal: &Principal{Subject: "s", Tenant: "acme"}, want: "acme"},
+		{name: "BoundPrincipalMatchingHeader", header: "acme", principal: &Principal{Subject: "s", Tenant: "acme"}, want: "acme"},
+		{name: "BoundPrincipalOtherTenant", header: "globex", principal: &Principal{Subject: "s", Tenant: "acme"}, wantErr: true},
+		{name: "UnboundPrincipal", principal: &Principal{Subject: "s", Roles: []string{RoleEditor}}, want: DefaultTenant},
+		{name: "UnboundPrincipalDefaultHeader", header: DefaultTenant, principal: &Principal{Subject: "s", Roles: []string{RoleEditor}}, want: DefaultTenant},
+		{name: "UnboundPrincipalOtherTenant", header: "globex", principal: &Principal{Subject: "s", Roles: []string{RoleEditor}}, wantErr: true},
+		{name: "UnboundAdmin", header: "globex", principal: &Principal{Subject: "s", Roles: []string{RoleAdmin}}, want: "globex"},
+		{name: "InvalidPrincipalTenant", principal: &Principal{Subject: "s", Tenant: "evil.#"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			req := httptest.NewRequest(http.MethodGet, "/items", nil)
+			if tt.header != "" {
+				req.Header.Set(TenantHeader, tt.header)
+			}
+			if tt.principal != nil {
+				req = req.WithContext(withPrincipal(req.Context(), tt.principal))
+			}
+
+			// Act
+			got, err := resolveTenant(req)
+
+			// Assert
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error; got tenant %q", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Expected tenant %q; got %q (%v)", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	// Arrange
+	useStore(t, 0)
+	eventPublisher = nil
+	mux := newMux()
+	do := func(method, path, tenant string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set(TenantHeader, tenant)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Act
+	do(http.MethodPost, "/items/add", "acme", Item{Name: "Anvil"})
+	do(http.MethodPost, "/items/add", "globex", Item{Name: "Laser"})
+	rec := do(http.MethodGet, "/items", "acme", nil)
+
+	// Assert
+	var got []Item
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "Anvil" || got[0].ID != 1 {
+		t.Errorf("Expected only acme's item; got %v", got)
+	}
+	if rec := do(http.MethodDelete, "/items/delete", "acme", Item{ID: 2}); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected another tenant's item to be invisible; got %v", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/items", "Not Valid", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected invalid tenant to be rejected; got %v", rec.Code)
+	}
+
+	t.Run("ReadsDoNotCreateTenants", func(t *testing.T) {
+		for _, path := range []string{"/items", "/items/snapshot"} {
+			do(http.MethodGet, path, "initech", nil)
+		}
+		do(http.MethodDelete, "/items/delete", "initech", Item{ID: 1})
+		if _, ok := store.tenants["initech"]; ok {
+			t.Error("Expected requests that change nothing to leave no tenant behind")
+		}
+	})
+}
+
+func TestTenantQuota(t *testing.T) {
+	// Arrange
+	useStore(t, 1, Item{ID: 1, Name: "Only"})
+	eventPublisher = nil
+	body, _ := json.Marshal(Item{Name: "One too many"})
+	req := httptest.NewRequest(http.MethodPost, "/items/add", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	// Act
+	newMux().ServeHTTP(rec, req)
+
+	// Assert
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status Forbidden; got %v", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected problem response; got %s", ct)
+	}
+}
+
+func TestTenantBoundAPIKey(t *testing.T) {
+	// Arrange
+	a := useAuthenticator(t, AuthConfig{Enabled: true})
+	_, key, _ := a.keys.Create("acme-editor", []string{RoleEditor}, "acme")
+	useStore(t, 0)
+	store.addLocked("acme", Item{Name: "Anvil"})
+	store.addLocked("globex", Item{Name: "Laser"})
+	mux := newMux()
+
+	t.Run("UsesBoundTenant", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var got []Item
+		json.NewDecoder(rec.Body).Decode(&got)
+		if len(got) != 1 || got[0].Name != "Anvil" {
+			t.Errorf("Expected acme's items; got %v", got)
+		}
+	})
+
+	t.Run("UnboundKeyCannotSelectTenant", func(t *testing.T) {
+		_, unbound, _ := a.keys.Create("reader", []string{RoleReader}, "")
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req.Header.Set(APIKeyHeader, unbound)
+		req.Header.Set(TenantHeader, "globex")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden; got %v", rec.Code)
+		}
+	})
+
+	t.Run("RejectsOtherTenant", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req.Header.Set(APIKeyHeader, key)
+		req.Header.Set(TenantHeader, "globex")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden; got %v", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Client certificate policies for TLSConfig.ClientAuth
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// AuthMethodClientCert marks principals authenticated by a client certificate
+const AuthMethodClientCert = "client_cert"
+
+// certReloader serves the certificate and client CA bundle from disk,
+// reloading them when the files change
+type certReloader struct {
+	certFile, keyFile, caFile string
+	interval                  time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  [3]time.Time
+	lastCheck time.Time
+	now       func() time.Time
+}
+
+// newCertReloader loads the files once, failing if any is invalid
+func newCertReloader(cfg TLSConfig) (*certReloader, error) {
+	cr := &certReloader{
+		certFile: cfg.CertFile,
+		keyFile:  cfg.KeyFile,
+		caFile:   cfg.ClientCAFile,
+		interval: cfg.ReloadInterval.Duration,
+		now:      time.Now,
+	}
+	if err := cr.reload(cr.statFiles()); err != nil {
+		return nil, err
+	}
+	cr.lastCheck = cr.now()
+	return cr, nil
+}
+
+// statFiles returns the modification times of the watched files
+func (cr *certReloader) statFiles() [3]time.Time {
+	var times [3]time.Time
+	for i, path := range []string{cr.certFile, cr.keyFile, cr.caFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			times[i] = info.ModTime()
+		}
+	}
+	return times
+}
+
+// reload reads the certificate, key and CA bundle
+func (cr *certReloader) reload(modTimes [3]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if cr.caFile != "" {
+		pem, err := os.ReadFile(cr.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("client CA bundle contains no certificates")
+		}
+	}
+	cr.cert, cr.clientCAs, cr.modTimes = &cert, pool, modTimes
+	return nil
+}
+
+// current returns the certificate and CA pool, first reloading them if the
+// files changed since the last check; a failed reload keeps serving the
+// previous certificate
+func (cr *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if now := cr.now(); now.Sub(cr.lastCheck) >= cr.interval {
+		cr.lastCheck = now
+		if modTimes := cr.statFiles(); modTimes != cr.modTimes {
+			if err := cr.reload(modTimes); err != nil {
+				slog.Error("failed to reload TLS certificate, keeping the previous one", "error", err)
+			} else {
+				slog.Info("reloaded TLS certificate", "cert_file", cr.certFile)
+			}
+		}
+	}
+	return cr.cert, cr.clientCAs
+}
+
+// newServerTLSConfig builds the listener TLS configuration. The certificate
+// and client CAs are resolved per handshake so reloads apply to new
+// connections without a restart.
+func newServerTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	cr, err := newCertReloader(cfg)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth := tls.NoClientCert
+	switch cfg.ClientAuth {
+	case ClientAuthOptional:
+		clientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: clientAuth,
+		NextProtos: []string{"http/1.1"},
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cert, clientCAs := cr.current()
+		c := base.Clone()
+		c.GetConfigForClient = nil
+		c.Certificates = []tls.Certificate{*cert}
+		c.ClientCAs = clientCAs
+		return c, nil
+	}
+	return base, nil
+}
+
+// principalFromCert maps a verified client certificate to a principal using
+// the configured subject mapping; unmapped subjects get no roles
+func principalFromCert(cert *x509.Certificate, mapping map[string]CertPrincipal) *Principal {
+	subject := cert.Subject.CommonName
+	p := &Principal{Subject: "cert:" + subject, Method: AuthMethodClientCert}
+	if m, ok := mapping[subject]; ok {
+		p.Roles = m.Roles
+		p.Tenant = m.Tenant
+	}
+	return p
+}
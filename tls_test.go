@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Could not create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns PEM encoded certificate and key for commonName
+func (ca *testCA) issue(t *testing.T, commonName string, serial int64, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Could not issue certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeTLSFiles writes a server certificate, key and client CA bundle
+func writeTLSFiles(t *testing.T, ca *testCA, serial int64) TLSConfig {
+	t.Helper()
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "localhost", serial, x509.ExtKeyUsageServerAuth)
+	cfg := TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		ClientAuth:   ClientAuthRequire,
+	}
+	os.WriteFile(cfg.CertFile, certPEM, 0o600)
+	os.WriteFile(cfg.KeyFile, keyPEM, 0o600)
+	os.WriteFile(cfg.ClientCAFile, ca.pem, 0o600)
+	return cfg
+}
+
+// startTLSServer serves h over TLS on a random port
+func startTLSServer(t *testing.T, cfg TLSConfig, h http.Handler) string {
+	t.Helper()
+	tlsConfig, err := newServerTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("Could not configure TLS: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	srv := &http.Server{Handler: h}
+	go srv.Serve(tls.NewListener(ln, tlsConfig))
+	t.Cleanup(func() { srv.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+// tlsClient trusts ca and presents the given client certificate, if any
+func tlsClient(t *testing.T, ca *testCA, certPEM, keyPEM []byte) *http.Client {
+	t.Helper()
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	cfg := &tls.Config{RootCAs: roots}
+	if certPEM != nil {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			t.Fatalf("Could not load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+}
+
+func TestMutualTLS(t *testing.T) {
+	// Arrange
+	ca := newTestCA(t)
+	cfg := writeTLSFiles(t, ca, 2)
+	cfg.ReloadInterval = Duration{time.Hour}
+	useAuthenticator(t, AuthConfig{Enabled: true, ClientCerts: map[string]CertPrincipal{
+		"billing-service": {Roles: []string{RoleReader}, Tenant: "acme"},
+	}})
+	useStore(t, 0)
+	store.addLocked("acme", Item{Name: "Anvil"})
+	url := startTLSServer(t, cfg, newMux())
+	certPEM, keyPEM := ca.issue(t, "billing-service", 3, x509.ExtKeyUsageClientAuth)
+
+	t.Run("ClientCertMapsToPrincipal", func(t *testing.T) {
+		resp, err := tlsClient(t, ca, certPEM, keyPEM).Get(url + "/items")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var got []Item
+		json.NewDecoder(resp.Body).Decode(&got)
+		if resp.StatusCode != http.StatusOK || len(got) != 1 || got[0].Name != "Anvil" {
+			t.Errorf("Expected the certificate's tenant items; got %v %v", resp.StatusCode, got)
+		}
+	})
+
+	t.Run("UnmappedSubjectHasNoRoles", func(t *testing.T) {
+		otherPEM, otherKey := ca.issue(t, "unknown-service", 4, x509.ExtKeyUsageClientAuth)
+		resp, err := tlsClient(t, ca, otherPEM, otherKey).Get(url + "/items")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden; got %v", resp.StatusCode)
+		}
+	})
+
+	t.Run("MissingClientCertRejected", func(t *testing.T) {
+		if resp, err := tlsClient(t, ca, nil, nil).Get(url + "/items"); err == nil {
+			resp.Body.Close()
+			t.Error("Expected the handshake to fail without a client certificate")
+		}
+	})
+
+	t.Run("UntrustedClientCertRejected", func(t *testing.T) {
+		rogue := newTestCA(t)
+		roguePEM, rogueKey := rogue.issue(t, "billing-service", 5, x509.ExtKeyUsageClientAuth)
+		if resp, err := tlsClient(t, ca, roguePEM, rogueKey).Get(url + "/items"); err == nil {
+			resp.Body.Close()
+			t.Error("Expected a certificate from another CA to be rejected")
+		}
+	})
+}
+
+func TestCertificateHotReload(t *testing.T) {
+	// Arrange
+	ca := newTestCA(t)
+	cfg := writeTLSFiles(t, ca, 10)
+	cfg.ClientAuth = ClientAuthNone
+	cfg.ReloadInterval = Duration{time.Nanosecond}
+	url := startTLSServer(t, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	client := tlsClient(t, ca, nil, nil)
+	serial := func() int64 {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+	if got := serial(); got != 10 {
+		t.Fatalf("Expected serial 10; got %d", got)
+	}
+
+	// Act
+	certPEM, keyPEM := ca.issue(t, "localhost", 11, x509.ExtKeyUsageServerAuth)
+	os.WriteFile(cfg.KeyFile, keyPEM, 0o600)
+	os.WriteFile(cfg.CertFile, certPEM, 0o600)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(cfg.CertFile, future, future)
+	os.Chtimes(cfg.KeyFile, future, future)
+
+	// Assert
+	if got := serial(); got != 11 {
+		t.Errorf("Expected the reloaded certificate with serial 11; got %d", got)
+	}
+}
+
+func TestCertificateReloadKeepsPreviousOnError(t *testing.T) {
+	// Arrange
+	ca := newTestCA(t)
+	cfg := writeTLSFiles(t, ca, 20)
+	cr, err := newCertReloader(cfg)
+	if err != nil {
+		t.Fatalf("Could not load certificate: %v", err)
+	}
+	before, _ := cr.current()
+
+	// Act
+	os.WriteFile(cfg.CertFile, []byte("not a certificate"), 0o600)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(cfg.CertFile, future, future)
+	after, _ := cr.current()
+
+	// Assert
+	if after != before {
+		t.Error("Expected the previous certificate to be kept after a failed reload")
+	}
+}
+
+func TestTLSConfigValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"CertWithoutKey", map[string]string{"TLS_CERT_FILE": "server.crt"}},
+		{"RequireWithoutCA", map[string]string{"TLS_CERT_FILE": "server.crt", "TLS_KEY_FILE": "server.key", "TLS_CLIENT_AUTH": "require"}},
+		{"UnknownClientAuth", map[string]string{"TLS_CLIENT_AUTH": "sometimes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := loadConfig(nil, envMap(tt.env)); err == nil {
+				t.Error("Expected configuration error")
+			}
+		})
+	}
+}
+
+func TestAuthenticateClientCertWithoutTLS(t *testing.T) {
+	a := useAuthenticator(t, AuthConfig{Enabled: true})
+	if _, err := a.Authenticate(httptest.NewRequest(http.MethodGet, "/items", nil)); err != errNoCredentials {
+		t.Errorf("Expected no credentials; got %v", err)
+	}
+}
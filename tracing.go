@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader is the W3C Trace Context header
+const TraceparentHeader = "traceparent"
+
+// TraceID identifies a whole trace
+type TraceID [16]byte
+
+// SpanID identifies one span within a trace
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the trace ID is non-zero
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether the span ID is non-zero
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext is the propagated part of a span
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats the span context as a W3C traceparent value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// parseTraceparent parses a version 00 W3C traceparent value
+func parseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+	if len(value) != 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, false
+	}
+	if value[:2] != "00" {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(value[3:35])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(value[36:52])); err != nil {
+		return sc, false
+	}
+	flags, err := strconv.ParseUint(value[53:], 16, 8)
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags&1 == 1
+	return sc, sc.IsValid()
+}
+
+// SpanData is a finished span handed to the exporter
+type SpanData struct {
+	Name         string            `json:"name"`
+	TraceID      string            `json:"traceId"`
+	SpanID       string            `json:"spanId"`
+	ParentSpanID string            `json:"parentSpanId,omitempty"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// SpanExporter receives finished, sampled spans
+type SpanExporter interface {
+	ExportSpan(span SpanData)
+}
+
+// InMemoryExporter keeps finished spans in memory
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// ExportSpan implements SpanExporter
+func (e *InMemoryExporter) ExportSpan(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns a copy of the exported spans
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// StdoutExporter writes each finished span as one JSON line
+type StdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewStdoutExporter creates an exporter writing to w
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	return &StdoutExporter{w: w}
+}
+
+// ExportSpan implements SpanExporter
+func (e *StdoutExporter) ExportSpan(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	json.NewEncoder(e.w).Encode(span)
+}
+
+// spanExporter receives spans from every tracer call; nil disables export
+var spanExporter SpanExporter
+
+// Span is an in-progress unit of work
+type Span struct {
+	name       string
+	sc         SpanContext
+	parent     SpanID
+	start      time.Time
+	mu         sync.Mutex
+	attributes map[string]string
+	err        error
+	ended      bool
+}
+
+// SpanContext returns the span's propagated identity
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+// SetAttribute records a key/value pair on the span
+func (s *Span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = fmt.Sprint(value)
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// End finishes the span and exports it when sampled
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		Name:       s.name,
+		TraceID:    s.sc.TraceID.String(),
+		SpanID:     s.sc.SpanID.String(),
+		Start:      s.start,
+		End:        time.Now(),
+		Attributes: s.attributes,
+	}
+	if s.parent.IsValid() {
+		data.ParentSpanID = s.parent.String()
+	}
+	if s.err != nil {
+		data.Error = s.err.Error()
+	}
+	s.mu.Unlock()
+
+	if exporter := spanExporter; exporter != nil && s.sc.Sampled {
+		exporter.ExportSpan(data)
+	}
+}
+
+type spanKey struct{}
+type remoteSpanKey struct{}
+
+// contextWithRemoteSpanContext records a span context received from another process
+func contextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteSpanKey{}, sc)
+}
+
+// spanFromContext returns the active span in ctx, if any
+func spanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// spanContextFromContext returns the active or remote parent span context
+func spanContextFromContext(ctx context.Context) SpanContext {
+	if span := spanFromContext(ctx); span != nil {
+		return span.sc
+	}
+	sc, _ := ctx.Value(remoteSpanKey{}).(SpanContext)
+	return sc
+}
+
+// startSpan starts a child of the span in ctx, or a new sampled root span
+func startSpan(ctx context.Context, name string) (context.Context, *Span) {
+	span := &Span{name: name, start: time.Now(), attributes: make(map[string]string)}
+	if parent := spanContextFromContext(ctx); parent.IsValid() {
+		span.sc.TraceID = parent.TraceID
+		span.sc.Sampled = parent.Sampled
+		span.parent = parent.SpanID
+	} else {
+		rand.Read(span.sc.TraceID[:])
+		span.sc.Sampled = true
+	}
+	rand.Read(span.sc.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// withTracing continues the caller's trace from traceparent, or starts a
+// new one, and wraps the request in a server span
+func withTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if sc, ok := parseTraceparent(r.Header.Get(TraceparentHeader)); ok {
+			ctx = contextWithRemoteSpanContext(ctx, sc)
+		}
+		ctx, span := startSpan(ctx, "HTTP "+r.Method+" "+r.URL.Path)
+		defer span.End()
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.Path)
+		if id := requestIDFromContext(ctx); id != "" {
+			span.SetAttribute("request.id", id)
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		span.SetAttribute("http.status_code", rec.status)
+		if rec.status >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("HTTP %d", rec.status))
+		}
+	})
+}
+
+// newSpanExporter builds the exporter named in the configuration
+func newSpanExporter(w io.Writer, cfg TracingConfig) (SpanExporter, error) {
+	switch cfg.Exporter {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return NewStdoutExporter(w), nil
+	case "memory":
+		return &InMemoryExporter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+}
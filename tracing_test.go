@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// useMemoryExporter installs an in-memory exporter for the duration of a test
+func useMemoryExporter(t *testing.T) *InMemoryExporter {
+	t.Helper()
+	exporter := &InMemoryExporter{}
+	previous := spanExporter
+	spanExporter = exporter
+	t.Cleanup(func() { spanExporter = previous })
+	return exporter
+}
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := parseTraceparent(testTraceparent)
+	if !ok {
+		t.Fatal("Expected valid traceparent")
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Errorf("Unexpected span context: %+v", sc)
+	}
+	if got := sc.Traceparent(); got != testTraceparent {
+		t.Errorf("Expected round trip %q; got %q", testTraceparent, got)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01",
+	} {
+		if _, ok := parseTraceparent(invalid); ok {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestStartSpan(t *testing.T) {
+	exporter := useMemoryExporter(t)
+
+	ctx, root := startSpan(context.Background(), "root")
+	_, child := startSpan(ctx, "child")
+	child.End()
+	root.End()
+	root.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans; got %d", len(spans))
+	}
+	if spans[0].TraceID != spans[1].TraceID {
+		t.Error("Expected child to share the root's trace ID")
+	}
+	if spans[0].ParentSpanID != spans[1].SpanID || spans[1].ParentSpanID != "" {
+		t.Errorf("Unexpected parent linkage: %+v", spans)
+	}
+}
+
+func TestWithTracingContinuesTrace(t *testing.T) {
+	// Arrange
+	exporter := useMemoryExporter(t)
+	useStore(t, 0)
+	eventPublisher = nil
+	body, _ := json.Marshal(Item{Name: "Traced"})
+	req := httptest.NewRequest(http.MethodPost, "/items/add", bytes.NewReader(body))
+	req.Header.Set(TraceparentHeader, testTraceparent)
+	rec := httptest.NewRecorder()
+
+	// Act
+	newHandler().ServeHTTP(rec, req)
+
+	// Assert
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status Created; got %v", rec.Code)
+	}
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected store and server spans; got %+v", spans)
+	}
+	store, server := spans[0], spans[1]
+	if store.Name != "store.add" || server.Name != "HTTP POST /items/add" {
+		t.Errorf("Unexpected span names: %q, %q", store.Name, server.Name)
+	}
+	for _, span := range spans {
+		if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Expected span %q to continue the caller's trace; got %s", span.Name, span.TraceID)
+		}
+	}
+	if server.ParentSpanID != "00f067aa0ba902b7" || store.ParentSpanID != server.SpanID {
+		t.Errorf("Unexpected parent linkage: %+v", spans)
+	}
+	if server.Attributes["http.status_code"] != "201" {
+		t.Errorf("Expected status attribute; got %v", server.Attributes)
+	}
+}
+
+func TestDeliveryContextContinuesTrace(t *testing.T) {
+	// Arrange
+	exporter := useMemoryExporter(t)
+	d := amqp.Delivery{
+		RoutingKey:    string(events.EventItemCreated),
+		CorrelationId: "req-9",
+		Headers:       amqp.Table{TraceparentHeader: testTraceparent},
+	}
+
+	// Act
+	handlerCtx, finish := startEventDelivery(d)
+	finish(events.DeliveryResult{Outcome: events.OutcomeProcessed, Event: &events.ItemEvent{Type: events.EventItemCreated}})
+
+	// Assert
+	if requestIDFromContext(handlerCtx) != "req-9" {
+		t.Error("Expected request ID in handler context")
+	}
+	sc := spanContextFromContext(handlerCtx)
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected handler context to continue trace; got %s", sc.TraceID)
+	}
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected one process span parented to the publisher; got %+v", spans)
+	}
+}
+
+func TestStoreSpans(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		body      string
+		wantSpan  string
+		wantError string
+	}{
+		{"List", http.MethodGet, "/items", "", "store.list", ""},
+		{"Snapshot", http.MethodGet, "/items/snapshot", "", "store.snapshot", ""},
+		{"UpdateMissing", http.MethodPut, "/items/update", `{"id":9,"name":"Ghost"}`, "store.update", "item not found"},
+		{"DeleteMissing", http.MethodDelete, "/items/delete", `{"id":9}`, "store.delete", "item not found"},
+		{"RestoreMissing", http.MethodPost, "/items/9/restore", "", "store.restore", "item not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			exporter := useMemoryExporter(t)
+			useStore(t, 0, Item{ID: 1, Name: "Anvil"})
+			eventPublisher = nil
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+
+			// Act
+			newHandler().ServeHTTP(httptest.NewRecorder(), req)
+
+			// Assert
+			spans := exporter.Spans()
+			if len(spans) != 2 {
+				t.Fatalf("Expected store and server spans; got %+v", spans)
+			}
+			if spans[0].Name != tt.wantSpan || spans[0].Attributes["tenant.id"] != "default" {
+				t.Errorf("Expected span %s for tenant default; got %+v", tt.wantSpan, spans[0])
+			}
+			if spans[0].Error != tt.wantError {
+				t.Errorf("Expected error %q; got %q", tt.wantError, spans[0].Error)
+			}
+		})
+	}
+}
+
+func TestNewSpanExporter(t *testing.T) {
+	if exporter, err := newSpanExporter(nil, TracingConfig{Exporter: "none"}); err != nil || exporter != nil {
+		t.Errorf("Expected no exporter; got %v, %v", exporter, err)
+	}
+	if _, err := newSpanExporter(nil, TracingConfig{Exporter: "zipkin"}); err == nil {
+		t.Error("Expected error for unknown exporter")
+	}
+
+	var buf bytes.Buffer
+	exporter, err := newSpanExporter(&buf, TracingConfig{Exporter: "stdout"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exporter.ExportSpan(SpanData{Name: "x"})
+	var got SpanData
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || got.Name != "x" {
+		t.Errorf("Expected JSON span on stdout exporter; got %q", buf.String())
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/us-service/Go-server-crud/pkg/events"
+)
+
+// includeDeleted reports whether the request lists items in the trash with
+// include=deleted
+func includeDeleted(r *http.Request) (bool, error) {
+	switch include := r.URL.Query().Get("include"); include {
+	case "":
+		return false, nil
+	case "deleted":
+		return true, nil
+	default:
+		return false, fmt.Errorf("include must be deleted; got %q", include)
+	}
+}
+
+// restoreItem takes a deleted item back out of the trash
+func restoreItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tenant := tenantFromContext(r.Context())
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		auditItem(r, tenant, AuditOpRestore, 0, nil, nil, AuditRejected, "invalid item ID")
+		writeProblem(w, r, http.StatusBadRequest, "item ID must be an integer")
+		return
+	}
+	_, span := startSpan(r.Context(), "store.restore")
+	span.SetAttribute("tenant.id", tenant)
+	span.SetAttribute("item.id", id)
+	defer span.End()
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	t := store.lookupLocked(tenant)
+	for i, item := range t.items {
+		if item.ID == id && item.DeletedAt != nil {
+			if store.quota > 0 && t.liveCount() >= store.quota {
+				span.RecordError(errQuotaExceeded)
+				auditItem(r, tenant, AuditOpRestore, id, &item, nil, AuditRejected, "quota exceeded")
+				writeProblem(w, r, http.StatusForbidden, fmt.Sprintf("tenant %q has reached its quota of %d items", tenant, store.quota))
+				return
+			}
+			restored := item
+			restored.DeletedAt = nil
+			t.items[i] = restored
+			store.touchLocked(tenant, id)
+			span.End()
+			auditItem(r, tenant, AuditOpRestore, id, &item, &restored, AuditSuccess, "")
+
+			// Publish event
+			if eventPublisher != nil {
+				event := itemEvent(r.Context(), events.EventItemRestored, tenant, restored)
+				if err := eventPublisher.PublishContext(r.Context(), event); err != nil {
+					slog.ErrorContext(r.Context(), "failed to publish event", "event_type", event.Type, "error", err)
+				}
+			}
+
+			setSequenceHeader(w, tenant)
+			json.NewEncoder(w).Encode(restored)
+			return
+		}
+	}
+	span.RecordError(errItemNotFound)
+	auditItem(r, tenant, AuditOpRestore, id, nil, nil, AuditRejected, "item not in trash")
+	http.Error(w, "Item not found", http.StatusNotFound)
+}
+
+// purgeDeleted removes the items deleted before cutoff, publishing
+// item.purged for each, and returns how many were purged
+func purgeDeleted(ctx context.Context, cutoff time.Time) int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	tenants := make([]string, 0, len(store.tenants))
+	for tenant := range store.tenants {
+		tenants = append(tenants, tenant)
+	}
+	slices.Sort(tenants)
+
+	purged := 0
+	for _, tenant := range tenants {
+		t := store.tenants[tenant]
+		kept := t.items[:0]
+		for _, item := range t.items {
+			if item.DeletedAt == nil || !item.DeletedAt.Before(cutoff) {
+				kept = append(kept, item)
+				continue
+			}
+			store.touchLocked(tenant, item.ID)
+			auditSystemItem(ctx, tenant, AuditOpPurge, item.ID, &item)
+			itemsPurgedTotal.Inc()
+			purged++
+
+			if eventPublisher != nil {
+				event := itemEvent(ctx, events.EventItemPurged, tenant, item)
+				if err := eventPublisher.PublishContext(ctx, event); err != nil {
+					slog.ErrorContext(ctx, "failed to publish event", "event_type", event.Type, "error", err)
+				}
+			}
+		}
+		clear(t.items[len(kept):])
+		t.items = kept
+	}
+	return purged
+}
+
+// runPurger purges the items that have been in the trash for longer than
+// retention every interval until ctx ends
+func runPurger(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n := purgeDeleted(ctx, now.Add(-retention)); n > 0 {
+				slog.InfoContext(ctx, "purged deleted items", "count", n, "retention", retention.String())
+			}
+		}
+	}
+}
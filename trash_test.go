@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// restoreRequest returns a request restoring item id
+func restoreRequest(id string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/items/"+id+"/restore", nil)
+	req.SetPathValue("id", id)
+	return req
+}
+
+func TestGetItemsIncludeDeleted(t *testing.T) {
+	deletedAt := time.Now().UTC()
+	useStore(t, 0, Item{ID: 1, Name: "Anvil"}, Item{ID: 2, Name: "Rocket", DeletedAt: &deletedAt})
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantCount  int
+	}{
+		{"", http.StatusOK, 1},
+		{"?include=deleted", http.StatusOK, 2},
+		{"?include=archived", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run("Query"+tt.query, func(t *testing.T) {
+			// Act
+			rec := httptest.NewRecorder()
+			getItems(rec, httptest.NewRequest(http.MethodGet, "/items"+tt.query, nil))
+
+			// Assert
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d; got %d", tt.wantStatus, rec.Code)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var got []Item
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("Could not decode response: %v", err)
+			}
+			if len(got) != tt.wantCount {
+				t.Errorf("Expected %d items; got %v", tt.wantCount, got)
+			}
+		})
+	}
+}
+
+func TestRestoreItem(t *testing.T) {
+	t.Run("Restores", func(t *testing.T) {
+		// Arrange
+		l := useAuditLog(t)
+		deletedAt := time.Now().UTC()
+		items := useStore(t, 0, Item{ID: 1, Name: "Anvil", DeletedAt: &deletedAt})
+		rec := httptest.NewRecorder()
+
+		// Act
+		restoreItem(rec, restoreRequest("1"))
+
+		// Assert
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status OK; got %v", rec.Code)
+		}
+		if items.items[0].DeletedAt != nil || items.versions[1] != 2 {
+			t.Errorf("Expected the item restored at version 2; got %+v at version %d", items.items[0], items.versions[1])
+		}
+		if got := rec.Header().Get(SequenceHeader); got != "2" {
+			t.Errorf("Expected %s 2; got %q", SequenceHeader, got)
+		}
+		records := l.Query(AuditFilter{})
+		if len(records) != 1 || records[0].Operation != AuditOpRestore || records[0].After == nil || records[0].After.DeletedAt != nil {
+			t.Errorf("Unexpected audit records: %+v", records)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		id         string
+		quota      int
+		wantStatus int
+	}{
+		{"NotDeleted", "1", 0, http.StatusNotFound},
+		{"Missing", "3", 0, http.StatusNotFound},
+		{"InvalidID", "x", 0, http.StatusBadRequest},
+		{"QuotaExceeded", "2", 1, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			useAuditLog(t)
+			deletedAt := time.Now().UTC()
+			useStore(t, tt.quota, Item{ID: 1, Name: "Anvil"}, Item{ID: 2, Name: "Rocket", DeletedAt: &deletedAt})
+			rec := httptest.NewRecorder()
+
+			// Act
+			restoreItem(rec, restoreRequest(tt.id))
+
+			// Assert
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d; got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	// Arrange
+	l := useAuditLog(t)
+	old, recent := time.Now().Add(-2*time.Hour), time.Now()
+	items := useStore(t, 0,
+		Item{ID: 1, Name: "Anvil"},
+		Item{ID: 2, Name: "Rocket", DeletedAt: &old},
+		Item{ID: 3, Name: "Magnet", DeletedAt: &recent},
+	)
+	sequence := store.tenantLocked(DefaultTenant).sequence
+	before := itemsPurgedTotal.Value()
+
+	// Act
+	n := purgeDeleted(context.Background(), time.Now().Add(-time.Hour))
+
+	// Assert
+	if n != 1 {
+		t.Errorf("Expected 1 purged item; got %d", n)
+	}
+	if len(items.items) != 2 || items.items[0].ID != 1 || items.items[1].ID != 3 {
+		t.Errorf("Expected only the item deleted before the cutoff to be purged; got %v", items.items)
+	}
+	if got := store.tenantLocked(DefaultTenant).sequence; got != sequence+1 {
+		t.Errorf("Expected the purge to advance the sequence to %d; got %d", sequence+1, got)
+	}
+	records := l.Query(AuditFilter{})
+	if len(records) != 1 || records[0].Operation != AuditOpPurge || records[0].Actor != systemActor || records[0].ItemID != 2 {
+		t.Errorf("Unexpected audit records: %+v", records)
+	}
+	if got := itemsPurgedTotal.Value() - before; got != 1 {
+		t.Errorf("Expected items_purged_total to grow by 1; got %v", got)
+	}
+}